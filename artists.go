@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+)
+
+// Configuration keys for artist formatting
+const (
+	artistFormatKey    = "artistformat"
+	artistSeparatorKey = "artistseparator"
+)
+
+// Artist formatting options
+const (
+	artistFormatDefault     = "Default"     // Artist tag as provided by Navidrome
+	artistFormatPrimary     = "Primary"     // First credited artist only
+	artistFormatAll         = "All"         // All credited artists, joined with the configured separator
+	artistFormatFeaturing   = "Featuring"   // "A feat. B & C"
+	artistFormatAlbumArtist = "AlbumArtist" // Album artist instead of track artists
+)
+
+const defaultArtistSeparator = ", "
+
+// formatArtists renders the track's artists according to the configured formatting policy.
+// The result is used for the displayed state, the activity name and Spotify search terms.
+func formatArtists(track scrobbler.TrackInfo) string {
+	format, _ := pdk.GetConfig(artistFormatKey)
+	separator := defaultArtistSeparator
+	if format == artistFormatAll || format == artistFormatAlbumArtist {
+		if sep, ok := pdk.GetConfig(artistSeparatorKey); ok && sep != "" {
+			separator = sep
+		}
+	}
+	return formatArtistsWith(track, format, separator)
+}
+
+// formatArtistsWith renders the track's artists using the given policy and separator.
+// It falls back to the flat Artist tag when the structured artist list is empty.
+func formatArtistsWith(track scrobbler.TrackInfo, format, separator string) string {
+	names := artistNames(track.Artists)
+
+	switch format {
+	case artistFormatPrimary:
+		if len(names) > 0 {
+			return names[0]
+		}
+	case artistFormatAll:
+		if len(names) > 0 {
+			return strings.Join(names, separator)
+		}
+	case artistFormatFeaturing:
+		switch len(names) {
+		case 0:
+		case 1:
+			return names[0]
+		default:
+			featured := names[1:]
+			last := featured[len(featured)-1]
+			if len(featured) == 1 {
+				return names[0] + " feat. " + last
+			}
+			return names[0] + " feat. " + strings.Join(featured[:len(featured)-1], ", ") + " & " + last
+		}
+	case artistFormatAlbumArtist:
+		if track.AlbumArtist != "" {
+			return track.AlbumArtist
+		}
+		if albumArtists := artistNames(track.AlbumArtists); len(albumArtists) > 0 {
+			return strings.Join(albumArtists, separator)
+		}
+	}
+
+	if track.Artist == "" && len(names) > 0 {
+		return strings.Join(names, separator)
+	}
+	return track.Artist
+}
+
+// primaryArtist returns the first credited artist, falling back to the flat Artist tag.
+func primaryArtist(track scrobbler.TrackInfo) string {
+	if names := artistNames(track.Artists); len(names) > 0 {
+		return names[0]
+	}
+	return track.Artist
+}
+
+// artistNames returns the non-empty names from a list of artist references.
+func artistNames(refs []scrobbler.ArtistRef) []string {
+	names := make([]string, 0, len(refs))
+	for _, a := range refs {
+		if name := strings.TrimSpace(a.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Artist formatting", func() {
+	multiArtist := scrobbler.TrackInfo{
+		Artist:       "Daft Punk feat. Pharrell Williams & Nile Rodgers",
+		Artists:      []scrobbler.ArtistRef{{Name: "Daft Punk"}, {Name: "Pharrell Williams"}, {Name: "Nile Rodgers"}},
+		AlbumArtist:  "Daft Punk",
+		AlbumArtists: []scrobbler.ArtistRef{{Name: "Daft Punk"}},
+	}
+
+	Describe("formatArtistsWith", func() {
+		DescribeTable("renders the artist credit",
+			func(track scrobbler.TrackInfo, format, separator, expected string) {
+				Expect(formatArtistsWith(track, format, separator)).To(Equal(expected))
+			},
+			Entry("default uses the flat artist tag", multiArtist, artistFormatDefault, ", ", "Daft Punk feat. Pharrell Williams & Nile Rodgers"),
+			Entry("unset format behaves like default", multiArtist, "", ", ", "Daft Punk feat. Pharrell Williams & Nile Rodgers"),
+			Entry("primary only", multiArtist, artistFormatPrimary, ", ", "Daft Punk"),
+			Entry("all with custom separator", multiArtist, artistFormatAll, " / ", "Daft Punk / Pharrell Williams / Nile Rodgers"),
+			Entry("featuring with several guests", multiArtist, artistFormatFeaturing, ", ", "Daft Punk feat. Pharrell Williams & Nile Rodgers"),
+			Entry("featuring with one guest",
+				scrobbler.TrackInfo{Artists: []scrobbler.ArtistRef{{Name: "A"}, {Name: "B"}}}, artistFormatFeaturing, ", ", "A feat. B"),
+			Entry("featuring with a single artist",
+				scrobbler.TrackInfo{Artists: []scrobbler.ArtistRef{{Name: "A"}}}, artistFormatFeaturing, ", ", "A"),
+			Entry("featuring with many guests",
+				scrobbler.TrackInfo{Artists: []scrobbler.ArtistRef{{Name: "A"}, {Name: "B"}, {Name: "C"}, {Name: "D"}}}, artistFormatFeaturing, ", ", "A feat. B, C & D"),
+			Entry("album artist", multiArtist, artistFormatAlbumArtist, ", ", "Daft Punk"),
+			Entry("album artist from refs",
+				scrobbler.TrackInfo{Artist: "X", AlbumArtists: []scrobbler.ArtistRef{{Name: "V"}, {Name: "W"}}}, artistFormatAlbumArtist, " & ", "V & W"),
+			Entry("primary falls back to the flat tag",
+				scrobbler.TrackInfo{Artist: "Solo"}, artistFormatPrimary, ", ", "Solo"),
+			Entry("default falls back to joined refs",
+				scrobbler.TrackInfo{Artists: []scrobbler.ArtistRef{{Name: "A"}, {Name: " "}, {Name: "B"}}}, artistFormatDefault, ", ", "A, B"),
+		)
+	})
+
+	Describe("formatArtists", func() {
+		BeforeEach(func() {
+			pdk.ResetMock()
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		})
+
+		It("reads the format and separator from config", func() {
+			pdk.PDKMock.On("GetConfig", artistFormatKey).Return(artistFormatAll, true)
+			pdk.PDKMock.On("GetConfig", artistSeparatorKey).Return(" · ", true)
+			Expect(formatArtists(multiArtist)).To(Equal("Daft Punk · Pharrell Williams · Nile Rodgers"))
+		})
+
+		It("uses the default separator when none is configured", func() {
+			pdk.PDKMock.On("GetConfig", artistFormatKey).Return(artistFormatAll, true)
+			pdk.PDKMock.On("GetConfig", artistSeparatorKey).Return("", false)
+			Expect(formatArtists(multiArtist)).To(Equal("Daft Punk, Pharrell Williams, Nile Rodgers"))
+		})
+	})
+
+	Describe("primaryArtist", func() {
+		It("prefers the first credited artist", func() {
+			Expect(primaryArtist(multiArtist)).To(Equal("Daft Punk"))
+		})
+
+		It("falls back to the flat artist tag", func() {
+			Expect(primaryArtist(scrobbler.TrackInfo{Artist: "Solo"})).To(Equal("Solo"))
+		})
+	})
+})
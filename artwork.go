@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+)
+
+// Configuration key for public artwork sources
+const publicArtworkKey = "publicartwork"
+
+// ArtworkSource finds album artwork on a public service, which Discord can fetch without
+// Navidrome being reachable or anything being uploaded.
+type ArtworkSource interface {
+	// Name identifies the source in cache keys and logs.
+	Name() string
+	// ArtworkURL returns the URL of the track's album cover, or "" when none is found. It
+	// returns an error when the lookup failed, so the miss is retried sooner.
+	ArtworkURL(track scrobbler.TrackInfo) (string, error)
+}
+
+// localArtworkSource is implemented by sources that build artwork URLs from the tags and
+// only check that the image exists. Like local link resolvers, their results are only
+// cached, leaving the resolution store to results that needed a search.
+type localArtworkSource interface {
+	localArtwork()
+}
+
+// artworkSources lists the public artwork sources in the order they are tried: exact
+// matches by MBID first, then searches by album artist and album name.
+var artworkSources = []ArtworkSource{
+	coverArtArchiveSource{},
+	iTunesArtworkSource{},
+	deezerArtworkSource{},
+}
+
+// findPublicArtwork returns the first public artwork URL found for the track, or "" when
+// public sources are disabled or none has a match. Results are cached per album.
+func findPublicArtwork(track scrobbler.TrackInfo) string {
+	if enabled, _ := pdk.GetConfig(publicArtworkKey); enabled != "true" {
+		return ""
+	}
+	if track.Album == "" && track.MBZAlbumID == "" && track.MBZReleaseGroupID == "" {
+		return ""
+	}
+	for _, source := range artworkSources {
+		cacheKey := artworkCacheKey(source.Name(), track)
+		lookup := func() (string, error) { return source.ArtworkURL(track) }
+		var artworkURL string
+		if _, local := source.(localArtworkSource); local {
+			artworkURL = cacheOnlyLookup(cacheKey, lookup)
+		} else {
+			artworkURL = cachedLookup(cacheKey, lookup)
+		}
+		if artworkURL != "" {
+			pdk.Log(pdk.LogDebug, fmt.Sprintf("Using %s artwork for %q: %s", source.Name(), track.Album, artworkURL))
+			return artworkURL
+		}
+	}
+	return ""
+}
+
+// artworkCacheKey returns the cache key for a source's artwork of the track's album.
+func artworkCacheKey(source string, track scrobbler.TrackInfo) string {
+	return "artwork." + strings.ToLower(source) + "." + hashKey(track.MBZAlbumID+"\x00"+track.MBZReleaseGroupID+"\x00"+
+		foldMetadata(albumArtist(track))+"\x00"+foldMetadata(track.Album))
+}
+
+// albumArtist returns the track's first album artist, falling back to its primary artist.
+func albumArtist(track scrobbler.TrackInfo) string {
+	if names := artistNames(track.AlbumArtists); len(names) > 0 {
+		return names[0]
+	}
+	if track.AlbumArtist != "" {
+		return track.AlbumArtist
+	}
+	return primaryArtist(track)
+}
+
+// sameAlbum reports whether a search result is the track's album, comparing folded tags.
+func sameAlbum(track scrobbler.TrackInfo, artist, album string) bool {
+	return foldMetadata(artist) == foldMetadata(albumArtist(track)) && foldMetadata(album) == foldMetadata(track.Album)
+}
+
+// ============================================================================
+// Cover Art Archive
+// ============================================================================
+
+type coverArtArchiveSource struct{}
+
+// coverArtArchiveIndex captures the relevant fields of a Cover Art Archive image index.
+type coverArtArchiveIndex struct {
+	Images []struct {
+		Front bool `json:"front"`
+	} `json:"images"`
+}
+
+func (coverArtArchiveSource) Name() string { return "CoverArtArchive" }
+
+func (coverArtArchiveSource) localArtwork() {}
+
+// ArtworkURL returns the 500px front cover of the release, or of the release group when the
+// release has none. Whether a front cover exists is checked in the entity's image index on
+// coverartarchive.org, which isn't subject to the musicbrainz.org throttle.
+func (coverArtArchiveSource) ArtworkURL(track scrobbler.TrackInfo) (string, error) {
+	for _, entity := range []struct{ kind, mbid string }{
+		{"release", track.MBZAlbumID},
+		{"release-group", track.MBZReleaseGroupID},
+	} {
+		if !isValidMBID(entity.mbid) {
+			continue
+		}
+		found, err := hasCoverArtArchiveFront(entity.kind, entity.mbid)
+		if err != nil {
+			pdk.Log(pdk.LogInfo, fmt.Sprintf("Cover Art Archive %s lookup failed for %s: %v", entity.kind, entity.mbid, err))
+			return "", fmt.Errorf("Cover Art Archive %s lookup failed: %w", entity.kind, err)
+		}
+		if found {
+			return "https://coverartarchive.org/" + entity.kind + "/" + entity.mbid + "/front-500", nil
+		}
+	}
+	return "", nil
+}
+
+// hasCoverArtArchiveFront reports whether Cover Art Archive has a front cover for the
+// release or release group. Entities without any artwork have no index and return 404.
+func hasCoverArtArchiveFront(kind, mbid string) (bool, error) {
+	resp, err := host.HTTPSend(host.HTTPRequest{Method: "GET", URL: "https://coverartarchive.org/" + kind + "/" + mbid})
+	if err != nil {
+		return false, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.StatusCode == 404 {
+		return false, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return false, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var index coverArtArchiveIndex
+	if err := json.Unmarshal(resp.Body, &index); err != nil {
+		return false, fmt.Errorf("failed to parse response: %w", err)
+	}
+	for _, img := range index.Images {
+		if img.Front {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ============================================================================
+// iTunes
+// ============================================================================
+
+type iTunesArtworkSource struct{}
+
+// iTunesAlbumResponse captures the relevant fields of an iTunes Search API album response.
+type iTunesAlbumResponse struct {
+	Results []struct {
+		ArtistName     string `json:"artistName"`
+		CollectionName string `json:"collectionName"`
+		ArtworkURL100  string `json:"artworkUrl100"`
+	} `json:"results"`
+}
+
+func (iTunesArtworkSource) Name() string { return "iTunes" }
+
+// ArtworkURL searches the iTunes Search API for the album. The 100px artwork URL is resized
+// to 600px through its path, which Apple's image server honors.
+func (iTunesArtworkSource) ArtworkURL(track scrobbler.TrackInfo) (string, error) {
+	artist := albumArtist(track)
+	if artist == "" || track.Album == "" {
+		return "", nil
+	}
+	var resp iTunesAlbumResponse
+	reqURL := "https://itunes.apple.com/search?media=music&entity=album&limit=10&term=" + url.QueryEscape(searchTerms(artist, track.Album))
+	if err := fetchJSON(reqURL, nil, &resp); err != nil {
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("iTunes artwork lookup failed: %v", err))
+		return "", fmt.Errorf("iTunes artwork lookup failed: %w", err)
+	}
+	for _, r := range resp.Results {
+		if r.ArtworkURL100 != "" && sameAlbum(track, r.ArtistName, r.CollectionName) {
+			return strings.Replace(r.ArtworkURL100, "100x100bb", "600x600bb", 1), nil
+		}
+	}
+	return "", nil
+}
+
+// ============================================================================
+// Deezer
+// ============================================================================
+
+type deezerArtworkSource struct{}
+
+// deezerAlbumSearchResponse captures the relevant fields of a Deezer album search response.
+type deezerAlbumSearchResponse struct {
+	Data []struct {
+		Title   string `json:"title"`
+		CoverXL string `json:"cover_xl"`
+		Artist  struct {
+			Name string `json:"name"`
+		} `json:"artist"`
+	} `json:"data"`
+}
+
+func (deezerArtworkSource) Name() string { return "Deezer" }
+
+// ArtworkURL searches the Deezer API for the album.
+func (deezerArtworkSource) ArtworkURL(track scrobbler.TrackInfo) (string, error) {
+	artist := albumArtist(track)
+	if artist == "" || track.Album == "" {
+		return "", nil
+	}
+	var resp deezerAlbumSearchResponse
+	query := fmt.Sprintf("artist:%q album:%q", artist, track.Album)
+	if err := fetchJSON("https://api.deezer.com/search/album?limit=10&q="+url.QueryEscape(query), nil, &resp); err != nil {
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Deezer artwork lookup failed: %v", err))
+		return "", fmt.Errorf("Deezer artwork lookup failed: %w", err)
+	}
+	for _, r := range resp.Data {
+		if r.CoverXL != "" && sameAlbum(track, r.Artist.Name, r.Title) {
+			return r.CoverXL, nil
+		}
+	}
+	return "", nil
+}
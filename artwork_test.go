@@ -0,0 +1,183 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Public artwork", func() {
+	const releaseID = "0b6b4ba0-d36f-47bd-b4ea-6a5b91842d29"
+	const releaseGroupID = "b1392450-e666-3926-a536-22c65f834433"
+
+	track := scrobbler.TrackInfo{
+		ID:           "track1",
+		Title:        "Airbag",
+		Album:        "OK Computer",
+		Artist:       "Radiohead",
+		AlbumArtists: []scrobbler.ArtistRef{{Name: "Radiohead"}},
+	}
+
+	// respond mocks the response to requests whose URL starts with prefix.
+	respond := func(prefix string, status int32, body string) {
+		host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+			return strings.HasPrefix(req.URL, prefix)
+		})).Return(&host.HTTPResponse{StatusCode: status, Body: []byte(body)}, nil)
+	}
+
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.HTTPMock.ExpectedCalls = nil
+		host.HTTPMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	Describe("findPublicArtwork", func() {
+		BeforeEach(func() {
+			host.CacheMock.On("GetString", mock.Anything).Return("", false, nil).Maybe()
+			host.CacheMock.On("SetString", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+		})
+
+		It("is disabled by default", func() {
+			pdk.PDKMock.On("GetConfig", publicArtworkKey).Return("", false)
+			Expect(findPublicArtwork(track)).To(BeEmpty())
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+
+		It("tries the sources in order", func() {
+			pdk.PDKMock.On("GetConfig", publicArtworkKey).Return("true", true)
+			respond("https://itunes.apple.com/", 200, `{"results":[]}`)
+			respond("https://api.deezer.com/", 200, `{"data":[{"title":"OK Computer","cover_xl":"https://e-cdns-images.dzcdn.net/xl.jpg","artist":{"name":"Radiohead"}}]}`)
+
+			Expect(findPublicArtwork(track)).To(Equal("https://e-cdns-images.dzcdn.net/xl.jpg"))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", artworkCacheKey("iTunes", track), "", linkCacheTTLMiss)
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", artworkCacheKey("Deezer", track), "https://e-cdns-images.dzcdn.net/xl.jpg", linkCacheTTLHit)
+		})
+
+		It("caches failed lookups only briefly and tries the next source", func() {
+			pdk.PDKMock.On("GetConfig", publicArtworkKey).Return("true", true)
+			respond("https://itunes.apple.com/", 503, ``)
+			respond("https://api.deezer.com/", 200, `{"data":[{"title":"OK Computer","cover_xl":"https://e-cdns-images.dzcdn.net/xl.jpg","artist":{"name":"Radiohead"}}]}`)
+
+			Expect(findPublicArtwork(track)).To(Equal("https://e-cdns-images.dzcdn.net/xl.jpg"))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", artworkCacheKey("iTunes", track), "", linkCacheTTLUnavailable)
+		})
+
+		It("falls through to the next source when Cover Art Archive has no cover", func() {
+			pdk.PDKMock.On("GetConfig", publicArtworkKey).Return("true", true)
+			t := track
+			t.MBZAlbumID = releaseID
+			respond("https://coverartarchive.org/release/"+releaseID, 404, ``)
+			respond("https://itunes.apple.com/", 200, `{"results":[{"artistName":"Radiohead","collectionName":"OK Computer","artworkUrl100":"https://is1-ssl.mzstatic.com/ok/100x100bb.jpg"}]}`)
+
+			Expect(findPublicArtwork(t)).To(Equal("https://is1-ssl.mzstatic.com/ok/600x600bb.jpg"))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", artworkCacheKey("CoverArtArchive", t), "", linkCacheTTLMiss)
+		})
+
+		It("keeps Cover Art Archive covers out of the resolution store", func() {
+			pdk.PDKMock.On("GetConfig", publicArtworkKey).Return("true", true)
+			t := track
+			t.MBZAlbumID = releaseID
+			respond("https://coverartarchive.org/release/"+releaseID, 200, `{"images":[{"front":true}]}`)
+
+			Expect(findPublicArtwork(t)).To(Equal("https://coverartarchive.org/release/" + releaseID + "/front-500"))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", artworkCacheKey("CoverArtArchive", t), "https://coverartarchive.org/release/"+releaseID+"/front-500", linkCacheTTLHit)
+			host.KVStoreMock.AssertNotCalled(GinkgoT(), "Set", mock.Anything, mock.Anything)
+		})
+
+		It("uses cached results", func() {
+			host.CacheMock.ExpectedCalls = nil
+			pdk.PDKMock.On("GetConfig", publicArtworkKey).Return("true", true)
+			host.CacheMock.On("GetString", artworkCacheKey("CoverArtArchive", track)).Return("https://archive.org/cached.jpg", true, nil)
+
+			Expect(findPublicArtwork(track)).To(Equal("https://archive.org/cached.jpg"))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+	})
+
+	Describe("artworkCacheKey", func() {
+		It("shares entries between tracks of the same album", func() {
+			other := track
+			other.ID, other.Title = "track2", "Paranoid Android"
+			Expect(artworkCacheKey("iTunes", other)).To(Equal(artworkCacheKey("iTunes", track)))
+		})
+	})
+
+	Describe("coverArtArchiveSource", func() {
+		It("returns the release's front cover when its index lists one", func() {
+			respond("https://coverartarchive.org/release/"+releaseID, 200, `{"images":[{"front":false},{"front":true}]}`)
+			t := track
+			t.MBZAlbumID, t.MBZReleaseGroupID = releaseID, releaseGroupID
+			Expect(coverArtArchiveSource{}.ArtworkURL(t)).To(Equal("https://coverartarchive.org/release/" + releaseID + "/front-500"))
+			host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", 1)
+		})
+
+		It("falls back to the release group when the release has no artwork", func() {
+			respond("https://coverartarchive.org/release/"+releaseID, 404, ``)
+			respond("https://coverartarchive.org/release-group/"+releaseGroupID, 200, `{"images":[{"front":true}]}`)
+			t := track
+			t.MBZAlbumID, t.MBZReleaseGroupID = releaseID, releaseGroupID
+			Expect(coverArtArchiveSource{}.ArtworkURL(t)).To(Equal("https://coverartarchive.org/release-group/" + releaseGroupID + "/front-500"))
+		})
+
+		It("returns empty when no index has a front cover", func() {
+			respond("https://coverartarchive.org/release/"+releaseID, 200, `{"images":[{"front":false}]}`)
+			respond("https://coverartarchive.org/release-group/"+releaseGroupID, 404, ``)
+			t := track
+			t.MBZAlbumID, t.MBZReleaseGroupID = releaseID, releaseGroupID
+			artworkURL, err := coverArtArchiveSource{}.ArtworkURL(t)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(artworkURL).To(BeEmpty())
+		})
+
+		It("returns an error when the lookup fails", func() {
+			respond("https://coverartarchive.org/", 503, ``)
+			t := track
+			t.MBZAlbumID = releaseID
+			artworkURL, err := coverArtArchiveSource{}.ArtworkURL(t)
+			Expect(artworkURL).To(BeEmpty())
+			Expect(err).To(MatchError(ContainSubstring("HTTP 503")))
+		})
+
+		It("needs an MBID", func() {
+			Expect(coverArtArchiveSource{}.ArtworkURL(track)).To(BeEmpty())
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+	})
+
+	Describe("iTunesArtworkSource", func() {
+		It("returns the matching album's artwork at 600px", func() {
+			respond("https://itunes.apple.com/search?media=music&entity=album", 200, `{"results":[
+				{"artistName":"Radiohead","collectionName":"OK Computer OKNOTOK 1997 2017","artworkUrl100":"https://is1-ssl.mzstatic.com/other/100x100bb.jpg"},
+				{"artistName":"Radiohead","collectionName":"OK Computer","artworkUrl100":"https://is1-ssl.mzstatic.com/ok/100x100bb.jpg"}]}`)
+			Expect(iTunesArtworkSource{}.ArtworkURL(track)).To(Equal("https://is1-ssl.mzstatic.com/ok/600x600bb.jpg"))
+		})
+
+		It("rejects albums by other artists", func() {
+			respond("https://itunes.apple.com/", 200, `{"results":[{"artistName":"Radiohead Tribute","collectionName":"OK Computer","artworkUrl100":"https://x/100x100bb.jpg"}]}`)
+			Expect(iTunesArtworkSource{}.ArtworkURL(track)).To(BeEmpty())
+		})
+	})
+
+	Describe("deezerArtworkSource", func() {
+		It("matches albums case-insensitively", func() {
+			respond("https://api.deezer.com/search/album", 200, `{"data":[{"title":"ok computer","cover_xl":"https://dz/xl.jpg","artist":{"name":"RADIOHEAD"}}]}`)
+			Expect(deezerArtworkSource{}.ArtworkURL(track)).To(Equal("https://dz/xl.jpg"))
+		})
+
+		It("returns an error when the lookup fails", func() {
+			respond("https://api.deezer.com/", 500, ``)
+			artworkURL, err := deezerArtworkSource{}.ArtworkURL(track)
+			Expect(artworkURL).To(BeEmpty())
+			Expect(err).To(MatchError(ContainSubstring("HTTP 500")))
+		})
+	})
+})
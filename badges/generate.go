@@ -0,0 +1,120 @@
+//go:build ignore
+
+// generate renders the audio format badges shown as the small image. Run it through
+// go generate after changing a badge; the PNGs are committed, so Discord can fetch them
+// from the repository.
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+const side = 256
+
+var (
+	lossyColor    = color.RGBA{0x54, 0x6e, 0x7a, 0xff}
+	losslessColor = color.RGBA{0x1e, 0x6f, 0xd9, 0xff}
+	hiResColor    = color.RGBA{0xd4, 0xa0, 0x17, 0xff}
+)
+
+// badge is one PNG: the codec label on a colored disc, with a "HI-RES" line for hi-res files.
+type badge struct {
+	file  string
+	label string
+	bg    color.RGBA
+	hiRes bool
+}
+
+func main() {
+	var badges []badge
+	for _, b := range []struct{ file, label string }{
+		{"flac", "FLAC"}, {"alac", "ALAC"}, {"wav", "WAV"}, {"aiff", "AIFF"}, {"ape", "APE"}, {"wv", "WV"},
+	} {
+		badges = append(badges,
+			badge{b.file, b.label, losslessColor, false},
+			badge{b.file + "-hires", b.label, hiResColor, true})
+	}
+	badges = append(badges, badge{"dsd", "DSD", hiResColor, true})
+	for _, b := range []struct{ file, label string }{
+		{"mp3", "MP3"}, {"aac", "AAC"}, {"ogg", "OGG"}, {"opus", "OPUS"}, {"wma", "WMA"},
+	} {
+		badges = append(badges, badge{b.file, b.label, lossyColor, false})
+	}
+
+	ttf, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, b := range badges {
+		img := render(ttf, b)
+		f, err := os.Create(filepath.Join("badges", b.file+".png"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := png.Encode(f, img); err != nil {
+			log.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func render(ttf *opentype.Font, b badge) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, side, side))
+
+	// Discord crops the small image to a circle, so the disc fills the image
+	var disc vector.Rasterizer
+	disc.Reset(side, side)
+	const k = 0.5523 // Cubic Bézier approximation of a quarter circle
+	c, r := float32(side)/2, float32(side)/2
+	disc.MoveTo(c+r, c)
+	disc.CubeTo(c+r, c+r*k, c+r*k, c+r, c, c+r)
+	disc.CubeTo(c-r*k, c+r, c-r, c+r*k, c-r, c)
+	disc.CubeTo(c-r, c-r*k, c-r*k, c-r, c, c-r)
+	disc.CubeTo(c+r*k, c-r, c+r, c-r*k, c+r, c)
+	disc.ClosePath()
+	disc.Draw(img, img.Bounds(), image.NewUniform(b.bg), image.Point{})
+
+	fg := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	if b.hiRes {
+		fg = color.RGBA{0x1a, 0x1a, 0x1a, 0xff}
+	}
+	baseline := side/2 + 28
+	if b.hiRes {
+		baseline = side/2 + 14
+		drawCentered(img, ttf, "HI-RES", 30, 110, side/2+62, fg)
+	}
+	drawCentered(img, ttf, b.label, 84, 190, baseline, fg)
+	return img
+}
+
+// drawCentered draws text horizontally centered on the baseline, at size points or smaller
+// so it fits within maxWidth pixels.
+func drawCentered(dst draw.Image, ttf *opentype.Font, text string, size float64, maxWidth, baseline int, fg color.Color) {
+	for ; size > 8; size-- {
+		face, err := opentype.NewFace(ttf, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			log.Fatal(err)
+		}
+		d := &font.Drawer{Dst: dst, Src: image.NewUniform(fg), Face: face}
+		width := d.MeasureString(text).Ceil()
+		if width <= maxWidth {
+			d.Dot = fixed.P((side-width)/2, baseline)
+			d.DrawString(text)
+			return
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// errServiceUnavailable is returned instead of calling a service whose circuit breaker is open.
+var errServiceUnavailable = errors.New("service unavailable")
+
+const (
+	breakerProbeTimeout int64 = 30           // Seconds a half-open probe may take before another is allowed
+	breakerStateTTL     int64 = 24 * 60 * 60 // Breaker state expires after a day without failures
+)
+
+// listenBrainzBreaker guards the ListenBrainz Labs Spotify lookups.
+var listenBrainzBreaker = circuitBreaker{Name: "listenbrainz", Threshold: 3, OpenFor: 5 * 60}
+
+// circuitBreaker stops calls to a failing service for a while. After Threshold consecutive
+// failures the circuit opens and calls are skipped for OpenFor seconds. Then a single probe
+// call is let through (half-open): its success closes the circuit, its failure reopens it.
+// Failure counts and deadlines are cached, so every lookup sees the same breaker state.
+type circuitBreaker struct {
+	Name      string
+	Threshold int
+	OpenFor   int64 // Seconds
+}
+
+// breakerState is the cached state of a circuit breaker.
+type breakerState struct {
+	Failures   int   `json:"failures"`             // Consecutive failures
+	OpenUntil  int64 `json:"openUntil,omitempty"`  // Unix seconds, 0 while closed
+	ProbeUntil int64 `json:"probeUntil,omitempty"` // Unix seconds, set while a probe is in flight
+}
+
+func (b circuitBreaker) cacheKey() string {
+	return "breaker." + b.Name
+}
+
+func (b circuitBreaker) load() (breakerState, bool) {
+	var state breakerState
+	cached, exists, err := host.CacheGetString(b.cacheKey())
+	if err != nil || !exists {
+		return state, false
+	}
+	if err := json.Unmarshal([]byte(cached), &state); err != nil {
+		return state, false
+	}
+	return state, true
+}
+
+func (b circuitBreaker) save(state breakerState) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	_ = host.CacheSetString(b.cacheKey(), string(data), breakerStateTTL)
+}
+
+// allow reports whether the service may be called at now.
+func (b circuitBreaker) allow(now time.Time) bool {
+	state, exists := b.load()
+	if !exists || state.OpenUntil == 0 {
+		return true
+	}
+	if now.Unix() < state.OpenUntil || now.Unix() < state.ProbeUntil {
+		return false
+	}
+	state.ProbeUntil = now.Unix() + breakerProbeTimeout
+	b.save(state)
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Circuit breaker %s half-open, probing", b.Name))
+	return true
+}
+
+// isOpen reports whether calls are being skipped at now. Unlike allow, it never starts a
+// probe, so it can be checked before work that only matters if the service is called.
+func (b circuitBreaker) isOpen(now time.Time) bool {
+	state, exists := b.load()
+	return exists && state.OpenUntil != 0 && (now.Unix() < state.OpenUntil || now.Unix() < state.ProbeUntil)
+}
+
+// report records the outcome of a call made at now.
+func (b circuitBreaker) report(failed bool, now time.Time) {
+	state, exists := b.load()
+	if !failed {
+		if exists {
+			_ = host.CacheRemove(b.cacheKey())
+			if state.OpenUntil != 0 {
+				pdk.Log(pdk.LogInfo, fmt.Sprintf("Circuit breaker %s closed", b.Name))
+			}
+		}
+		return
+	}
+
+	state.Failures++
+	if state.OpenUntil != 0 || state.Failures >= b.Threshold {
+		state.OpenUntil = now.Unix() + b.OpenFor
+		state.ProbeUntil = 0
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Circuit breaker %s open for %ds after %d consecutive failures", b.Name, b.OpenFor, state.Failures))
+	}
+	b.save(state)
+}
+
+// isServiceFailure reports whether an HTTP call failed on the service's side: a transport
+// error, a server error or rate limiting. Other error statuses are answers, not failures.
+func isServiceFailure(resp *host.HTTPResponse, err error) bool {
+	if err != nil || resp == nil {
+		return true
+	}
+	return resp.StatusCode >= 500 || resp.StatusCode == 429
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("circuitBreaker", func() {
+	breaker := circuitBreaker{Name: "test", Threshold: 2, OpenFor: 60}
+	now := time.Unix(1_000_000, 0)
+
+	// withState mocks the cached breaker state; an empty state means none is cached.
+	withState := func(state string) {
+		host.CacheMock.On("GetString", "breaker.test").Return(state, state != "", nil)
+	}
+
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		host.CacheMock.On("SetString", "breaker.test", mock.Anything, breakerStateTTL).Return(nil).Maybe()
+		host.CacheMock.On("Remove", "breaker.test").Return(nil).Maybe()
+	})
+
+	Describe("allow", func() {
+		It("allows calls while closed", func() {
+			withState("")
+			Expect(breaker.allow(now)).To(BeTrue())
+		})
+
+		It("allows calls after failures below the threshold", func() {
+			withState(`{"failures":1}`)
+			Expect(breaker.allow(now)).To(BeTrue())
+		})
+
+		It("skips calls while open", func() {
+			withState(`{"failures":2,"openUntil":1000030}`)
+			Expect(breaker.allow(now)).To(BeFalse())
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("lets a single probe through once the open window has passed", func() {
+			withState(`{"failures":2,"openUntil":999990}`)
+			Expect(breaker.allow(now)).To(BeTrue())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "breaker.test", `{"failures":2,"openUntil":999990,"probeUntil":1000030}`, breakerStateTTL)
+		})
+
+		It("skips calls while a probe is in flight", func() {
+			withState(`{"failures":2,"openUntil":999990,"probeUntil":1000030}`)
+			Expect(breaker.allow(now)).To(BeFalse())
+		})
+	})
+
+	Describe("isOpen", func() {
+		It("is closed without failures", func() {
+			withState("")
+			Expect(breaker.isOpen(now)).To(BeFalse())
+		})
+
+		It("is open until the open window has passed", func() {
+			withState(`{"failures":2,"openUntil":1000030}`)
+			Expect(breaker.isOpen(now)).To(BeTrue())
+		})
+
+		It("doesn't start a probe once the open window has passed", func() {
+			withState(`{"failures":2,"openUntil":999990}`)
+			Expect(breaker.isOpen(now)).To(BeFalse())
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", mock.Anything, mock.Anything, mock.Anything)
+		})
+	})
+
+	Describe("report", func() {
+		It("counts consecutive failures", func() {
+			withState("")
+			breaker.report(true, now)
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "breaker.test", `{"failures":1}`, breakerStateTTL)
+		})
+
+		It("opens the circuit at the threshold", func() {
+			withState(`{"failures":1}`)
+			breaker.report(true, now)
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "breaker.test", `{"failures":2,"openUntil":1000060}`, breakerStateTTL)
+		})
+
+		It("reopens the circuit when the probe fails", func() {
+			withState(`{"failures":2,"openUntil":999990,"probeUntil":1000030}`)
+			breaker.report(true, now)
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "breaker.test", `{"failures":3,"openUntil":1000060}`, breakerStateTTL)
+		})
+
+		It("closes the circuit on success", func() {
+			withState(`{"failures":2,"openUntil":999990,"probeUntil":1000030}`)
+			breaker.report(false, now)
+			host.CacheMock.AssertCalled(GinkgoT(), "Remove", "breaker.test")
+		})
+
+		It("does not write on success while closed", func() {
+			withState("")
+			breaker.report(false, now)
+			host.CacheMock.AssertNotCalled(GinkgoT(), "Remove", mock.Anything)
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", mock.Anything, mock.Anything, mock.Anything)
+		})
+	})
+
+	DescribeTable("isServiceFailure",
+		func(resp *host.HTTPResponse, err error, expected bool) {
+			Expect(isServiceFailure(resp, err)).To(Equal(expected))
+		},
+		Entry("transport error", nil, errors.New("timeout"), true),
+		Entry("server error", &host.HTTPResponse{StatusCode: 502}, nil, true),
+		Entry("rate limited", &host.HTTPResponse{StatusCode: 429}, nil, true),
+		Entry("not found", &host.HTTPResponse{StatusCode: 404}, nil, false),
+		Entry("success", &host.HTTPResponse{StatusCode: 200}, nil, false),
+	)
+})
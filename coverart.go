@@ -1,114 +1,178 @@
 package main
 
 import (
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"strings"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/navidrome/navidrome/plugins/pdk/go/host"
 	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
 )
 
-// Configuration key for uguu.se image hosting
+// Configuration key for the legacy uguu.se toggle, superseded by the image host setting
 const uguuEnabledKey = "uguuenabled"
 
-// uguu.se API response
-type uguuResponse struct {
-	Success bool `json:"success"`
-	Files   []struct {
-		URL string `json:"url"`
-	} `json:"files"`
-}
-
-// getImageURL retrieves the track artwork URL, optionally uploading to uguu.se.
-func getImageURL(username, trackID string) string {
-	uguuEnabled, _ := pdk.GetConfig(uguuEnabledKey)
-	if uguuEnabled == "true" {
-		return getImageViaUguu(username, trackID)
+// privateFallbackHost is the image host used when no host is configured but Navidrome's
+// artwork URLs point to a private address, which Discord can't fetch.
+var privateFallbackHost ImageHost = uguuHost{}
+
+// getImageURL retrieves the track artwork URL. Public artwork sources are tried first, when
+// enabled, so nothing is uploaded for albums they know. Otherwise the artwork is uploaded to
+// the image host, shared through Navidrome, or Navidrome's own artwork URL is used. When
+// that URL is private, the artwork is uploaded to privateFallbackHost instead.
+func getImageURL(username string, track scrobbler.TrackInfo) string {
+	if artworkURL := findPublicArtwork(track); artworkURL != "" {
+		return artworkURL
+	}
+	if name, _ := pdk.GetConfig(imageHostKey); name == imageHostShare {
+		if artworkURL := getImageViaShare(username, track.ID); artworkURL != "" {
+			return artworkURL
+		}
 	}
-	return getImageDirect(trackID)
+	if imageHost := getImageHost(); imageHost != nil {
+		return getImageViaHost(imageHost, username, track.ID, int64(track.Duration))
+	}
+	artworkURL := getImageDirect(track.ID)
+	if artworkURL != "" && isPrivateURL(artworkURL) {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Artwork URL %s is private, uploading to %s", artworkURL, privateFallbackHost.Name()))
+		return getImageViaHost(privateFallbackHost, username, track.ID, int64(track.Duration))
+	}
+	return artworkURL
 }
 
-// getImageDirect returns the artwork URL directly from Navidrome (current behavior).
+// getImageDirect returns the artwork URL directly from Navidrome.
 func getImageDirect(trackID string) string {
 	artworkURL, err := host.ArtworkGetTrackUrl(trackID, 300)
 	if err != nil {
 		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to get artwork URL: %v", err))
 		return ""
 	}
+	return artworkURL
+}
 
-	// Don't use localhost URLs
-	if strings.HasPrefix(artworkURL, "http://localhost") {
-		return ""
+// albumArtworkTTL is how long the content hash of an album's artwork is remembered. It is
+// short so changed artwork is picked up, as a stale hash only costs a local fetch.
+const albumArtworkTTL int64 = 4 * 60 * 60
+
+// artworkRefreshMargin is how long a temporary upload must outlive the track it is shown
+// for. Uploads closer to their expiry are replaced.
+const artworkRefreshMargin int64 = 10 * 60
+
+// getImageViaHost fetches artwork and uploads it to the image host. Uploads are deduplicated
+// in two layers: the album's artwork hash is remembered, so the other tracks of the album
+// don't fetch it again, and uploaded URLs are keyed by that hash, so albums sharing artwork
+// share an upload. Temporary uploads are only reused while they outlive the track, which
+// lasts duration seconds, so long sessions re-upload before the host deletes the file.
+func getImageViaHost(imageHost ImageHost, username, trackID string, duration int64) string {
+	albumKey := ""
+	if song, err := getSong(username, trackID); err == nil && song.AlbumID != "" {
+		albumKey = fmt.Sprintf("%s.artwork.album.%s", imageHost.Name(), song.AlbumID)
 	}
-	return artworkURL
+	return uploadCoverArt(imageHost, username, trackID, albumKey, duration)
 }
 
-// getImageViaUguu fetches artwork and uploads it to uguu.se.
-func getImageViaUguu(username, trackID string) string {
-	// Check cache first
-	cacheKey := fmt.Sprintf("uguu.artwork.%s", trackID)
-	cachedURL, exists, err := host.CacheGetString(cacheKey)
-	if err == nil && exists {
-		pdk.Log(pdk.LogDebug, fmt.Sprintf("Cache hit for uguu artwork: %s", trackID))
-		return cachedURL
+// uploadCoverArt fetches the cover art with the given ID from Navidrome and uploads it to
+// the image host, reusing earlier uploads of the same artwork. When artworkHashKey is set,
+// the artwork hash is remembered under it, so the artwork isn't fetched again while its
+// upload can be reused.
+func uploadCoverArt(imageHost ImageHost, username, coverArtID, artworkHashKey string, duration int64) string {
+	now := time.Now().Unix()
+
+	if artworkHashKey != "" {
+		if hash, exists, err := host.CacheGetString(artworkHashKey); err == nil && exists {
+			if cachedURL, ok := reusableUpload(artworkUploadKey(imageHost, hash), duration, now); ok {
+				pdk.Log(pdk.LogDebug, fmt.Sprintf("Cache hit for %s artwork: %s", imageHost.Name(), artworkHashKey))
+				return cachedURL
+			}
+		}
 	}
 
-	// Fetch artwork data from Navidrome
-	contentType, data, err := host.SubsonicAPICallRaw(fmt.Sprintf("/getCoverArt?u=%s&id=%s&size=300", username, trackID))
+	// Fetch artwork data from Navidrome, already scaled down to the configured size
+	size := getArtworkSize()
+	contentType, data, err := host.SubsonicAPICallRaw(fmt.Sprintf("/getCoverArt?u=%s&id=%s&size=%d", url.QueryEscape(username), url.QueryEscape(coverArtID), size))
 	if err != nil {
 		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to fetch artwork data: %v", err))
 		return ""
 	}
+	hash := contentHash(data)
+	if artworkHashKey != "" {
+		_ = host.CacheSetString(artworkHashKey, hash, albumArtworkTTL)
+	}
 
-	// Upload to uguu.se
-	url, err := uploadToUguu(data, contentType)
+	// Check cache and resolution store for the same artwork uploaded before
+	uploadKey := artworkUploadKey(imageHost, hash)
+	if cachedURL, ok := reusableUpload(uploadKey, duration, now); ok {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Cache hit for %s artwork %s", imageHost.Name(), hash))
+		return cachedURL
+	}
+
+	// The hash is of the fetched artwork, so already uploaded artwork isn't processed again
+	data, contentType = prepareArtwork(data, contentType, size, getArtworkQuality())
+	upload, err := imageHost.Upload(data, contentType)
 	if err != nil {
-		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to upload to uguu.se: %v", err))
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to upload artwork to %s: %v", imageHost.Name(), err))
 		return ""
 	}
 
-	_ = host.CacheSetString(cacheKey, url, 9000)
-	return url
+	// Temporary uploads are deleted by the host, so the stored URL expires with the cache entry
+	ttl := artworkCacheTTL(upload.Retention)
+	storeTTL := int64(0)
+	if upload.Retention > 0 {
+		storeTTL = ttl
+		tieredSet(artworkExpiryKey(upload.URL), strconv.FormatInt(now+upload.Retention, 10), upload.Retention, upload.Retention)
+	}
+	tieredSet(uploadKey, upload.URL, ttl, storeTTL)
+	return upload.URL
 }
 
-// uploadToUguu uploads image data to uguu.se and returns the file URL.
-func uploadToUguu(imageData []byte, contentType string) (string, error) {
-	// Build multipart/form-data body manually (TinyGo-compatible)
-	boundary := "----NavidromeCoverArt"
-	var body []byte
-	body = append(body, []byte(fmt.Sprintf("--%s\r\n", boundary))...)
-	body = append(body, []byte(fmt.Sprintf("Content-Disposition: form-data; name=\"files[]\"; filename=\"cover.jpg\"\r\n"))...)
-	body = append(body, []byte(fmt.Sprintf("Content-Type: %s\r\n", contentType))...)
-	body = append(body, []byte("\r\n")...)
-	body = append(body, imageData...)
-	body = append(body, []byte(fmt.Sprintf("\r\n--%s--\r\n", boundary))...)
-
-	resp, err := host.HTTPSend(host.HTTPRequest{
-		Method:  "POST",
-		URL:     "https://uguu.se/upload",
-		Headers: map[string]string{"Content-Type": fmt.Sprintf("multipart/form-data; boundary=%s", boundary)},
-		Body:    body,
-	})
-	if err != nil {
-		return "", fmt.Errorf("uguu.se upload failed: %w", err)
+// reusableUpload returns the upload cached under uploadKey, unless it expires less than
+// artworkRefreshMargin after a track of duration seconds played from now would end. Uploads
+// too close to their expiry are forgotten, along with the Discord asset made from them.
+func reusableUpload(uploadKey string, duration, now int64) (string, bool) {
+	cachedURL, exists := tieredGet(uploadKey, linkCacheTTLHit)
+	if !exists {
+		return "", false
 	}
-	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("uguu.se upload failed: HTTP %d", resp.StatusCode)
+	expiresAt, ok := artworkExpiresAt(cachedURL)
+	if !ok || expiresAt >= now+duration+artworkRefreshMargin {
+		return cachedURL, true
 	}
 
-	var result uguuResponse
-	if err := json.Unmarshal(resp.Body, &result); err != nil {
-		return "", fmt.Errorf("failed to parse uguu.se response: %w", err)
-	}
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Artwork upload %s expires in %ds, uploading again", cachedURL, expiresAt-now))
+	tieredRemove(uploadKey)
+	tieredRemove(artworkExpiryKey(cachedURL))
+	clientID, _ := pdk.GetConfig(clientIDKey)
+	tieredRemove(discordAssetCacheKey(clientID, cachedURL))
+	return "", false
+}
 
-	if !result.Success || len(result.Files) == 0 {
-		return "", fmt.Errorf("uguu.se upload was not successful")
-	}
+// artworkExpiryKey returns the cache key recording when the host deletes an uploaded file.
+func artworkExpiryKey(uploadURL string) string {
+	return "artwork.expiry." + hashKey(uploadURL)
+}
 
-	if result.Files[0].URL == "" {
-		return "", fmt.Errorf("uguu.se returned empty URL")
+// artworkExpiresAt returns when the host deletes the uploaded file at uploadURL, in Unix
+// seconds. ok is false for URLs that aren't temporary uploads.
+func artworkExpiresAt(uploadURL string) (expiresAt int64, ok bool) {
+	value, exists := tieredGet(artworkExpiryKey(uploadURL), linkCacheTTLHit)
+	if !exists {
+		return 0, false
 	}
+	expiresAt, err := strconv.ParseInt(value, 10, 64)
+	return expiresAt, err == nil
+}
+
+// artworkUploadKey returns the cache key of the image host's upload of the artwork with hash.
+func artworkUploadKey(imageHost ImageHost, hash string) string {
+	return fmt.Sprintf("%s.artwork.%s", imageHost.Name(), hash)
+}
 
-	return result.Files[0].URL, nil
+// contentHash returns a hex SHA-256 prefix (128 bits) identifying the data.
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
 }
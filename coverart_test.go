@@ -2,9 +2,13 @@ package main
 
 import (
 	"errors"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/navidrome/navidrome/plugins/pdk/go/host"
 	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
 	"github.com/stretchr/testify/mock"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -12,6 +16,16 @@ import (
 )
 
 var _ = Describe("getImageURL", func() {
+	fakeArt := []byte("fake-image-data")
+	fakeHash := contentHash(fakeArt)
+
+	// withAlbum mocks the song details of track1, on the album with albumID.
+	withAlbum := func(albumID string) {
+		host.CacheMock.On("GetString", "subsonic.song.testuser.track1").Return("", false, nil)
+		host.CacheMock.On("SetString", "subsonic.song.testuser.track1", mock.Anything, songCacheTTL).Return(nil)
+		host.SubsonicAPIMock.On("Call", "/getSong?u=testuser&id=track1").
+			Return(`{"subsonic-response":{"status":"ok","song":{"id":"track1","albumId":"`+albumID+`"}}}`, nil)
+	}
 	BeforeEach(func() {
 		pdk.ResetMock()
 		host.CacheMock.ExpectedCalls = nil
@@ -23,54 +37,95 @@ var _ = Describe("getImageURL", func() {
 		host.HTTPMock.ExpectedCalls = nil
 		host.HTTPMock.Calls = nil
 		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		pdk.PDKMock.On("GetConfig", publicArtworkKey).Return("", false).Maybe()
+		pdk.PDKMock.On("GetConfig", artworkSizeKey).Return("", false).Maybe()
+		pdk.PDKMock.On("GetConfig", artworkQualityKey).Return("", false).Maybe()
+		pdk.PDKMock.On("GetConfig", clientIDKey).Return("client123", true).Maybe()
+		host.CacheMock.On("GetString", artworkExpiryArg).Return("", false, nil).Maybe()
 	})
 
 	Describe("uguu disabled (default)", func() {
 		BeforeEach(func() {
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return("", false)
 			pdk.PDKMock.On("GetConfig", uguuEnabledKey).Return("", false)
 		})
 
 		It("returns artwork URL directly", func() {
 			host.ArtworkMock.On("GetTrackUrl", "track1", int32(300)).Return("https://example.com/art.jpg", nil)
 
-			url := getImageURL("testuser", "track1")
+			url := getImageURL("testuser", scrobbler.TrackInfo{ID: "track1"})
 			Expect(url).To(Equal("https://example.com/art.jpg"))
 		})
 
-		It("returns empty for localhost URL", func() {
-			host.ArtworkMock.On("GetTrackUrl", "track1", int32(300)).Return("http://localhost:4533/art.jpg", nil)
+		It("uploads artwork to uguu.se when the artwork URL is private", func() {
+			host.ArtworkMock.On("GetTrackUrl", "track1", int32(300)).Return("http://192.168.1.10:4533/share/img/abc", nil)
+			withAlbum("album1")
+			host.CacheMock.On("GetString", mock.Anything).Return("", false, nil)
+			host.CacheMock.On("SetString", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			host.SubsonicAPIMock.On("CallRaw", "/getCoverArt?u=testuser&id=track1&size=300").
+				Return("image/jpeg", fakeArt, nil)
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return req.URL == "https://uguu.se/upload"
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"success":true,"files":[{"url":"https://a.uguu.se/uploaded.jpg"}]}`)}, nil)
+
+			url := getImageURL("testuser", scrobbler.TrackInfo{ID: "track1"})
+			Expect(url).To(Equal("https://a.uguu.se/uploaded.jpg"))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "uguu.artwork."+fakeHash, "https://a.uguu.se/uploaded.jpg", int64(9000))
+		})
+
+		It("returns empty when the upload for a localhost URL fails", func() {
+			host.ArtworkMock.On("GetTrackUrl", "track1", int32(300)).Return("https://localhost:4533/art.jpg", nil)
+			withAlbum("album1")
+			host.CacheMock.On("GetString", mock.Anything).Return("", false, nil)
+			host.CacheMock.On("SetString", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			host.SubsonicAPIMock.On("CallRaw", mock.Anything).Return("", []byte(nil), errors.New("fetch failed"))
 
-			url := getImageURL("testuser", "track1")
+			url := getImageURL("testuser", scrobbler.TrackInfo{ID: "track1"})
 			Expect(url).To(BeEmpty())
 		})
 
 		It("returns empty when artwork fetch fails", func() {
 			host.ArtworkMock.On("GetTrackUrl", "track1", int32(300)).Return("", errors.New("not found"))
 
-			url := getImageURL("testuser", "track1")
+			url := getImageURL("testuser", scrobbler.TrackInfo{ID: "track1"})
 			Expect(url).To(BeEmpty())
 		})
 	})
 
 	Describe("uguu enabled", func() {
 		BeforeEach(func() {
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return("", false)
 			pdk.PDKMock.On("GetConfig", uguuEnabledKey).Return("true", true)
+			withAlbum("album1")
 		})
 
-		It("returns cached URL when available", func() {
-			host.CacheMock.On("GetString", "uguu.artwork.track1").Return("https://a.uguu.se/cached.jpg", true, nil)
+		It("returns the cached URL of the album's artwork", func() {
+			host.CacheMock.On("GetString", "uguu.artwork.album.album1").Return(fakeHash, true, nil)
+			host.CacheMock.On("GetString", "uguu.artwork."+fakeHash).Return("https://a.uguu.se/cached.jpg", true, nil)
 
-			url := getImageURL("testuser", "track1")
+			url := getImageURL("testuser", scrobbler.TrackInfo{ID: "track1"})
 			Expect(url).To(Equal("https://a.uguu.se/cached.jpg"))
+			host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "CallRaw", mock.Anything)
 		})
 
-		It("uploads artwork and caches the result", func() {
-			host.CacheMock.On("GetString", "uguu.artwork.track1").Return("", false, nil)
+		It("reuses an upload of the same artwork from another album", func() {
+			host.CacheMock.On("GetString", "uguu.artwork.album.album1").Return("", false, nil)
+			host.SubsonicAPIMock.On("CallRaw", "/getCoverArt?u=testuser&id=track1&size=300").
+				Return("image/jpeg", fakeArt, nil)
+			host.CacheMock.On("SetString", "uguu.artwork.album.album1", fakeHash, albumArtworkTTL).Return(nil)
+			host.CacheMock.On("GetString", "uguu.artwork."+fakeHash).Return("https://a.uguu.se/shared.jpg", true, nil)
+
+			url := getImageURL("testuser", scrobbler.TrackInfo{ID: "track1"})
+			Expect(url).To(Equal("https://a.uguu.se/shared.jpg"))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
 
-			// Mock SubsonicAPICallRaw
-			imageData := []byte("fake-image-data")
+		It("uploads artwork and caches the result", func() {
+			host.CacheMock.On("GetString", "uguu.artwork.album.album1").Return("", false, nil)
+			host.CacheMock.On("GetString", "uguu.artwork."+fakeHash).Return("", false, nil)
 			host.SubsonicAPIMock.On("CallRaw", "/getCoverArt?u=testuser&id=track1&size=300").
-				Return("image/jpeg", imageData, nil)
+				Return("image/jpeg", fakeArt, nil)
+			host.CacheMock.On("SetString", "uguu.artwork.album.album1", fakeHash, albumArtworkTTL).Return(nil)
 
 			// Mock uguu.se HTTP upload
 			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
@@ -78,33 +133,188 @@ var _ = Describe("getImageURL", func() {
 			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"success":true,"files":[{"url":"https://a.uguu.se/uploaded.jpg"}]}`)}, nil)
 
 			// Mock cache set
-			host.CacheMock.On("SetString", "uguu.artwork.track1", "https://a.uguu.se/uploaded.jpg", int64(9000)).Return(nil)
+			host.CacheMock.On("SetString", "uguu.artwork."+fakeHash, "https://a.uguu.se/uploaded.jpg", int64(9000)).Return(nil)
+			host.CacheMock.On("SetString", artworkExpiryArg, mock.Anything, uguuRetention).Return(nil)
 
-			url := getImageURL("testuser", "track1")
+			url := getImageURL("testuser", scrobbler.TrackInfo{ID: "track1"})
 			Expect(url).To(Equal("https://a.uguu.se/uploaded.jpg"))
-			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "uguu.artwork.track1", "https://a.uguu.se/uploaded.jpg", int64(9000))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "uguu.artwork."+fakeHash, "https://a.uguu.se/uploaded.jpg", int64(9000))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "uguu.artwork.album.album1", fakeHash, albumArtworkTTL)
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", artworkExpiryKey("https://a.uguu.se/uploaded.jpg"), mock.MatchedBy(func(v string) bool {
+				expiresAt, err := strconv.ParseInt(v, 10, 64)
+				return err == nil && expiresAt > time.Now().Unix()+uguuRetention-60
+			}), uguuRetention)
+		})
+
+		It("uploads again when the cached upload expires before the track ends", func() {
+			oldURL := "https://a.uguu.se/old.jpg"
+			host.CacheMock.ExpectedCalls = nil
+			withAlbum("album1")
+			host.CacheMock.On("GetString", "uguu.artwork.album.album1").Return(fakeHash, true, nil)
+			host.CacheMock.On("GetString", "uguu.artwork."+fakeHash).Return(oldURL, true, nil).Once()
+			host.CacheMock.On("GetString", artworkExpiryKey(oldURL)).Return(strconv.FormatInt(time.Now().Unix()+300, 10), true, nil)
+			host.CacheMock.On("Remove", mock.Anything).Return(nil)
+			host.CacheMock.On("GetString", "uguu.artwork."+fakeHash).Return("", false, nil)
+			host.CacheMock.On("SetString", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			host.SubsonicAPIMock.On("CallRaw", "/getCoverArt?u=testuser&id=track1&size=300").
+				Return("image/jpeg", fakeArt, nil)
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return req.URL == "https://uguu.se/upload"
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"success":true,"files":[{"url":"https://a.uguu.se/new.jpg"}]}`)}, nil)
+
+			url := getImageURL("testuser", scrobbler.TrackInfo{ID: "track1", Duration: 240})
+			Expect(url).To(Equal("https://a.uguu.se/new.jpg"))
+			host.CacheMock.AssertCalled(GinkgoT(), "Remove", discordAssetCacheKey("client123", oldURL))
+			host.KVStoreMock.AssertCalled(GinkgoT(), "Delete", storeKeyPrefix+discordAssetCacheKey("client123", oldURL))
+			host.CacheMock.AssertCalled(GinkgoT(), "Remove", artworkExpiryKey(oldURL))
+		})
+
+		It("reuses an upload that outlives the track", func() {
+			oldURL := "https://a.uguu.se/old.jpg"
+			host.CacheMock.ExpectedCalls = nil
+			withAlbum("album1")
+			host.CacheMock.On("GetString", "uguu.artwork.album.album1").Return(fakeHash, true, nil)
+			host.CacheMock.On("GetString", "uguu.artwork."+fakeHash).Return(oldURL, true, nil)
+			host.CacheMock.On("GetString", artworkExpiryKey(oldURL)).Return(strconv.FormatInt(time.Now().Unix()+3600, 10), true, nil)
+
+			url := getImageURL("testuser", scrobbler.TrackInfo{ID: "track1", Duration: 240})
+			Expect(url).To(Equal(oldURL))
 		})
 
 		It("returns empty when artwork data fetch fails", func() {
-			host.CacheMock.On("GetString", "uguu.artwork.track1").Return("", false, nil)
+			host.CacheMock.On("GetString", "uguu.artwork.album.album1").Return("", false, nil)
 			host.SubsonicAPIMock.On("CallRaw", "/getCoverArt?u=testuser&id=track1&size=300").
 				Return("", []byte(nil), errors.New("fetch failed"))
 
-			url := getImageURL("testuser", "track1")
+			url := getImageURL("testuser", scrobbler.TrackInfo{ID: "track1"})
 			Expect(url).To(BeEmpty())
 		})
 
 		It("returns empty when uguu.se upload fails", func() {
-			host.CacheMock.On("GetString", "uguu.artwork.track1").Return("", false, nil)
+			host.CacheMock.On("GetString", "uguu.artwork.album.album1").Return("", false, nil)
+			host.CacheMock.On("GetString", "uguu.artwork."+fakeHash).Return("", false, nil)
+			host.CacheMock.On("SetString", "uguu.artwork.album.album1", fakeHash, albumArtworkTTL).Return(nil)
 			host.SubsonicAPIMock.On("CallRaw", "/getCoverArt?u=testuser&id=track1&size=300").
-				Return("image/jpeg", []byte("fake-image-data"), nil)
+				Return("image/jpeg", fakeArt, nil)
 
 			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
 				return req.URL == "https://uguu.se/upload"
 			})).Return(&host.HTTPResponse{StatusCode: 500, Body: []byte(`{"success":false}`)}, nil)
 
-			url := getImageURL("testuser", "track1")
+			url := getImageURL("testuser", scrobbler.TrackInfo{ID: "track1"})
 			Expect(url).To(BeEmpty())
 		})
 	})
+
+	Describe("image host configured", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return(imageHostCatbox, true)
+			withAlbum("")
+			host.CacheMock.On("GetString", "catbox.artwork."+fakeHash).Return("", false, nil)
+			host.SubsonicAPIMock.On("CallRaw", "/getCoverArt?u=testuser&id=track1&size=300").
+				Return("image/jpeg", fakeArt, nil)
+		})
+
+		It("ignores the legacy uguu.se toggle", func() {
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return req.URL == "https://catbox.moe/user/api.php"
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte("https://files.catbox.moe/abc123.jpg")}, nil)
+			host.CacheMock.On("SetString", "catbox.artwork."+fakeHash, "https://files.catbox.moe/abc123.jpg", linkCacheTTLHit).Return(nil)
+
+			url := getImageURL("testuser", scrobbler.TrackInfo{ID: "track1"})
+			Expect(url).To(Equal("https://files.catbox.moe/abc123.jpg"))
+			pdk.PDKMock.AssertNotCalled(GinkgoT(), "GetConfig", uguuEnabledKey)
+		})
+
+		It("persists uploads the host keeps without an expiry", func() {
+			host.HTTPMock.On("Send", mock.Anything).
+				Return(&host.HTTPResponse{StatusCode: 200, Body: []byte("https://files.catbox.moe/abc123.jpg")}, nil)
+			host.CacheMock.On("SetString", "catbox.artwork."+fakeHash, mock.Anything, mock.Anything).Return(nil)
+
+			getImageURL("testuser", scrobbler.TrackInfo{ID: "track1"})
+			host.KVStoreMock.AssertCalled(GinkgoT(), "Set", "store.catbox.artwork."+fakeHash, mock.MatchedBy(func(data []byte) bool {
+				return !strings.Contains(string(data), `"e":`)
+			}))
+		})
+	})
+
+	Describe("Navidrome share", func() {
+		It("falls back to the artwork URL when the album can't be shared", func() {
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return(imageHostShare, true)
+			pdk.PDKMock.On("GetConfig", uguuEnabledKey).Return("", false).Maybe()
+			host.CacheMock.On("GetString", "subsonic.song.testuser.track1").Return("", false, nil)
+			host.SubsonicAPIMock.On("Call", "/getSong?u=testuser&id=track1").Return("", errors.New("unavailable"))
+			host.ArtworkMock.On("GetTrackUrl", "track1", int32(300)).Return("https://example.com/art.jpg", nil)
+
+			url := getImageURL("testuser", scrobbler.TrackInfo{ID: "track1"})
+			Expect(url).To(Equal("https://example.com/art.jpg"))
+		})
+	})
+
+	Describe("artwork processing", func() {
+		It("fetches artwork at the configured size and uploads it as JPEG", func() {
+			pdk.PDKMock.ExpectedCalls = nil
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return(imageHostCatbox, true)
+			pdk.PDKMock.On("GetConfig", artworkSizeKey).Return("128", true)
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			withAlbum("")
+			host.CacheMock.On("GetString", mock.Anything).Return("", false, nil)
+			host.CacheMock.On("SetString", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			host.SubsonicAPIMock.On("CallRaw", "/getCoverArt?u=testuser&id=track1&size=128").
+				Return("image/png", encodePNG(160, 128), nil)
+			var sent host.HTTPRequest
+			host.HTTPMock.On("Send", mock.Anything).Run(func(args mock.Arguments) {
+				sent = args.Get(0).(host.HTTPRequest)
+			}).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte("https://files.catbox.moe/abc123.jpg")}, nil)
+
+			url := getImageURL("testuser", scrobbler.TrackInfo{ID: "track1"})
+			Expect(url).To(Equal("https://files.catbox.moe/abc123.jpg"))
+			Expect(string(sent.Body)).To(ContainSubstring("filename=\"cover.jpg\"\r\nContent-Type: image/jpeg\r\n"))
+			Expect(string(sent.Body)).ToNot(ContainSubstring("PNG"))
+		})
+	})
+
+	Describe("public artwork enabled", func() {
+		track := scrobbler.TrackInfo{ID: "track1", Album: "OK Computer", AlbumArtist: "Radiohead", MBZAlbumID: "0b6b4ba0-d36f-47bd-b4ea-6a5b91842d29"}
+
+		BeforeEach(func() {
+			host.CacheMock.ExpectedCalls = nil
+			pdk.PDKMock.ExpectedCalls = nil
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			pdk.PDKMock.On("GetConfig", publicArtworkKey).Return("true", true)
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return(imageHostUguu, true)
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			host.CacheMock.On("GetString", mock.Anything).Return("", false, nil)
+			host.CacheMock.On("SetString", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		})
+
+		It("uses public artwork without uploading", func() {
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return req.URL == "https://coverartarchive.org/release/0b6b4ba0-d36f-47bd-b4ea-6a5b91842d29"
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"images":[{"front":true}]}`)}, nil)
+
+			url := getImageURL("testuser", track)
+			Expect(url).To(Equal("https://coverartarchive.org/release/0b6b4ba0-d36f-47bd-b4ea-6a5b91842d29/front-500"))
+			host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", 1)
+			host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "CallRaw", mock.Anything)
+		})
+
+		It("uploads when no public source has the album", func() {
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return req.URL != "https://uguu.se/upload"
+			})).Return(&host.HTTPResponse{StatusCode: 404}, nil)
+			withAlbum("album1")
+			host.SubsonicAPIMock.On("CallRaw", "/getCoverArt?u=testuser&id=track1&size=300").
+				Return("image/jpeg", []byte("fake-image-data"), nil)
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return req.URL == "https://uguu.se/upload"
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"success":true,"files":[{"url":"https://a.uguu.se/uploaded.jpg"}]}`)}, nil)
+
+			untagged := track
+			untagged.MBZAlbumID = ""
+			url := getImageURL("testuser", untagged)
+			Expect(url).To(Equal("https://a.uguu.se/uploaded.jpg"))
+		})
+	})
 })
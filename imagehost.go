@@ -0,0 +1,328 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// Configuration keys for image hosting
+const (
+	imageHostKey      = "imagehost"
+	nullPointerURLKey = "nullpointerurl"
+	imgurClientIDKey  = "imgurclientid"
+)
+
+// Image host names, as used in the image host setting
+const (
+	imageHostNavidrome   = "Navidrome"
+	imageHostUguu        = "uguu.se"
+	imageHostCatbox      = "Catbox"
+	imageHostLitterbox   = "Litterbox"
+	imageHostNullPointer = "0x0.st"
+	imageHostImgur       = "Imgur"
+	imageHostS3          = "S3"
+)
+
+const (
+	defaultNullPointerURL = "https://0x0.st"
+	litterboxTime         = "72h" // Longest retention Litterbox offers
+	multipartBoundary     = "----NavidromeCoverArt"
+)
+
+// Host retention, in seconds
+const (
+	uguuRetention        int64 = 3 * 60 * 60
+	litterboxRetention   int64 = 72 * 60 * 60
+	nullPointerRetention int64 = 30 * 24 * 60 * 60 // Minimum 0x0.st retention, used when X-Expires is missing
+)
+
+// ImageHost uploads artwork to a public host, so Discord can fetch it from instances
+// that aren't publicly accessible.
+type ImageHost interface {
+	// Name identifies the host in cache keys and logs.
+	Name() string
+	// Upload stores the image and returns its public URL and how long it stays available.
+	Upload(data []byte, contentType string) (imageUpload, error)
+}
+
+// imageUpload is an uploaded image.
+type imageUpload struct {
+	URL       string
+	Retention int64 // Seconds the file stays available, 0 when the host keeps it
+}
+
+// artworkCacheTTL returns how long an uploaded URL is reused: 5/6 of the host's retention,
+// so a cached URL is never handed to Discord just before the file is deleted. Uploads the
+// host keeps are cached like resolved links.
+func artworkCacheTTL(retention int64) int64 {
+	if retention <= 0 {
+		return linkCacheTTLHit
+	}
+	return retention - retention/6
+}
+
+// getImageHost returns the configured image host, or nil to use Navidrome's artwork URLs.
+// The legacy uguu.se toggle is honored unless another host is selected, as the settings
+// form saves the Navidrome default for users who enabled it before hosts were selectable.
+func getImageHost() ImageHost {
+	name, _ := pdk.GetConfig(imageHostKey)
+	if name == "" || name == imageHostNavidrome {
+		if uguuEnabled, _ := pdk.GetConfig(uguuEnabledKey); uguuEnabled == "true" {
+			name = imageHostUguu
+		}
+	}
+
+	switch name {
+	case "", imageHostNavidrome, imageHostShare:
+		return nil
+	case imageHostUguu:
+		return uguuHost{}
+	case imageHostCatbox:
+		return catboxHost{}
+	case imageHostLitterbox:
+		return litterboxHost{}
+	case imageHostNullPointer:
+		baseURL, _ := pdk.GetConfig(nullPointerURLKey)
+		if baseURL == "" {
+			baseURL = defaultNullPointerURL
+		}
+		return nullPointerHost{BaseURL: strings.TrimSuffix(baseURL, "/")}
+	case imageHostImgur:
+		clientID, _ := pdk.GetConfig(imgurClientIDKey)
+		if clientID == "" {
+			pdk.Log(pdk.LogWarn, "Imgur image host needs a client ID, using Navidrome artwork URLs")
+			return nil
+		}
+		return imgurHost{ClientID: clientID}
+	case imageHostS3:
+		cfg, err := getS3Config()
+		if err != nil {
+			pdk.Log(pdk.LogWarn, fmt.Sprintf("S3 image host is not configured: %v, using Navidrome artwork URLs", err))
+			return nil
+		}
+		return s3Host{Config: cfg}
+	}
+	pdk.Log(pdk.LogWarn, fmt.Sprintf("Unknown image host %q, using Navidrome artwork URLs", name))
+	return nil
+}
+
+// formField is a plain multipart/form-data field.
+type formField struct {
+	Name  string
+	Value string
+}
+
+// multipartBody builds a multipart/form-data body with the given fields followed by the file.
+// It is built by hand, as mime/multipart isn't TinyGo-compatible.
+func multipartBody(fields []formField, fileField string, data []byte, contentType string) []byte {
+	var body []byte
+	for _, f := range fields {
+		body = append(body, fmt.Sprintf("--%s\r\nContent-Disposition: form-data; name=%q\r\n\r\n%s\r\n", multipartBoundary, f.Name, f.Value)...)
+	}
+	body = append(body, fmt.Sprintf("--%s\r\n", multipartBoundary)...)
+	body = append(body, fmt.Sprintf("Content-Disposition: form-data; name=%q; filename=%q\r\n", fileField, "cover"+imageExtension(contentType))...)
+	body = append(body, fmt.Sprintf("Content-Type: %s\r\n\r\n", contentType)...)
+	body = append(body, data...)
+	body = append(body, fmt.Sprintf("\r\n--%s--\r\n", multipartBoundary)...)
+	return body
+}
+
+// imageExtension returns the file extension for an image content type.
+func imageExtension(contentType string) string {
+	switch strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0])) {
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	case "image/gif":
+		return ".gif"
+	}
+	return ".jpg"
+}
+
+// postMultipart uploads a multipart/form-data body. Error messages are prefixed with the
+// host name.
+func postMultipart(name, uploadURL string, headers map[string]string, body []byte) (*host.HTTPResponse, error) {
+	reqHeaders := map[string]string{"Content-Type": "multipart/form-data; boundary=" + multipartBoundary}
+	for k, v := range headers {
+		reqHeaders[k] = v
+	}
+	resp, err := host.HTTPSend(host.HTTPRequest{Method: "POST", URL: uploadURL, Headers: reqHeaders, Body: body})
+	if err != nil {
+		return nil, fmt.Errorf("%s upload failed: %w", name, err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("%s upload failed: HTTP %d", name, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// plainTextURL returns the file URL from a host that answers with the URL as plain text.
+func plainTextURL(name string, body []byte) (string, error) {
+	fileURL := strings.TrimSpace(string(body))
+	if !strings.HasPrefix(fileURL, "https://") && !strings.HasPrefix(fileURL, "http://") {
+		return "", fmt.Errorf("%s returned an unexpected response: %.100q", name, fileURL)
+	}
+	return fileURL, nil
+}
+
+// headerValue returns a response header, matched case-insensitively.
+func headerValue(headers map[string]string, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// ============================================================================
+// uguu.se
+// ============================================================================
+
+// uguu.se API response
+type uguuResponse struct {
+	Success bool `json:"success"`
+	Files   []struct {
+		URL string `json:"url"`
+	} `json:"files"`
+}
+
+// uguuHost uploads to uguu.se, which deletes files after 3 hours.
+type uguuHost struct{}
+
+func (uguuHost) Name() string { return "uguu" }
+
+func (uguuHost) Upload(data []byte, contentType string) (imageUpload, error) {
+	resp, err := postMultipart("uguu.se", "https://uguu.se/upload", nil, multipartBody(nil, "files[]", data, contentType))
+	if err != nil {
+		return imageUpload{}, err
+	}
+
+	var result uguuResponse
+	if err := json.Unmarshal(resp.Body, &result); err != nil {
+		return imageUpload{}, fmt.Errorf("failed to parse uguu.se response: %w", err)
+	}
+	if !result.Success || len(result.Files) == 0 {
+		return imageUpload{}, fmt.Errorf("uguu.se upload was not successful")
+	}
+	if result.Files[0].URL == "" {
+		return imageUpload{}, fmt.Errorf("uguu.se returned empty URL")
+	}
+	return imageUpload{URL: result.Files[0].URL, Retention: uguuRetention}, nil
+}
+
+// ============================================================================
+// Catbox and Litterbox
+// ============================================================================
+
+// catboxHost uploads anonymously to catbox.moe, which keeps files.
+type catboxHost struct{}
+
+func (catboxHost) Name() string { return "catbox" }
+
+func (catboxHost) Upload(data []byte, contentType string) (imageUpload, error) {
+	fields := []formField{{Name: "reqtype", Value: "fileupload"}}
+	resp, err := postMultipart("catbox.moe", "https://catbox.moe/user/api.php", nil, multipartBody(fields, "fileToUpload", data, contentType))
+	if err != nil {
+		return imageUpload{}, err
+	}
+	fileURL, err := plainTextURL("catbox.moe", resp.Body)
+	if err != nil {
+		return imageUpload{}, err
+	}
+	return imageUpload{URL: fileURL}, nil
+}
+
+// litterboxHost uploads to Litterbox, Catbox's temporary host, for 72 hours.
+type litterboxHost struct{}
+
+func (litterboxHost) Name() string { return "litterbox" }
+
+func (litterboxHost) Upload(data []byte, contentType string) (imageUpload, error) {
+	fields := []formField{{Name: "reqtype", Value: "fileupload"}, {Name: "time", Value: litterboxTime}}
+	resp, err := postMultipart("Litterbox", "https://litterbox.catbox.moe/resources/internals/api.php", nil, multipartBody(fields, "fileToUpload", data, contentType))
+	if err != nil {
+		return imageUpload{}, err
+	}
+	fileURL, err := plainTextURL("Litterbox", resp.Body)
+	if err != nil {
+		return imageUpload{}, err
+	}
+	return imageUpload{URL: fileURL, Retention: litterboxRetention}, nil
+}
+
+// ============================================================================
+// 0x0.st
+// ============================================================================
+
+// nullPointerHost uploads to a 0x0.st-style host ("The Null Pointer"). Retention depends on
+// the file size and is read from the X-Expires header, in Unix milliseconds.
+type nullPointerHost struct {
+	BaseURL string
+}
+
+func (nullPointerHost) Name() string { return "0x0" }
+
+func (h nullPointerHost) Upload(data []byte, contentType string) (imageUpload, error) {
+	resp, err := postMultipart(h.BaseURL, h.BaseURL+"/", nil, multipartBody(nil, "file", data, contentType))
+	if err != nil {
+		return imageUpload{}, err
+	}
+	fileURL, err := plainTextURL(h.BaseURL, resp.Body)
+	if err != nil {
+		return imageUpload{}, err
+	}
+
+	retention := nullPointerRetention
+	if expires, err := strconv.ParseInt(headerValue(resp.Headers, "X-Expires"), 10, 64); err == nil {
+		if remaining := expires/1000 - time.Now().Unix(); remaining > 0 {
+			retention = remaining
+		}
+	}
+	return imageUpload{URL: fileURL, Retention: retention}, nil
+}
+
+// ============================================================================
+// Imgur
+// ============================================================================
+
+// Imgur API response
+type imgurResponse struct {
+	Success bool `json:"success"`
+	Data    struct {
+		Link string `json:"link"`
+	} `json:"data"`
+}
+
+// imgurHost uploads anonymously to Imgur with an application client ID. Imgur keeps
+// anonymous uploads.
+type imgurHost struct {
+	ClientID string
+}
+
+func (imgurHost) Name() string { return "imgur" }
+
+func (h imgurHost) Upload(data []byte, contentType string) (imageUpload, error) {
+	fields := []formField{{Name: "type", Value: "file"}}
+	headers := map[string]string{"Authorization": "Client-ID " + h.ClientID}
+	resp, err := postMultipart("Imgur", "https://api.imgur.com/3/image", headers, multipartBody(fields, "image", data, contentType))
+	if err != nil {
+		return imageUpload{}, err
+	}
+
+	var result imgurResponse
+	if err := json.Unmarshal(resp.Body, &result); err != nil {
+		return imageUpload{}, fmt.Errorf("failed to parse Imgur response: %w", err)
+	}
+	if !result.Success || result.Data.Link == "" {
+		return imageUpload{}, fmt.Errorf("upload to Imgur was not successful")
+	}
+	return imageUpload{URL: result.Data.Link}, nil
+}
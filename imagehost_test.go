@@ -0,0 +1,200 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Image hosts", func() {
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.HTTPMock.ExpectedCalls = nil
+		host.HTTPMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	// uploadTo mocks a successful upload to uploadURL and returns the request it received.
+	uploadTo := func(uploadURL string, resp *host.HTTPResponse) *host.HTTPRequest {
+		var sent host.HTTPRequest
+		host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+			return req.URL == uploadURL
+		})).Run(func(args mock.Arguments) {
+			sent = args.Get(0).(host.HTTPRequest)
+		}).Return(resp, nil)
+		return &sent
+	}
+
+	Describe("getImageHost", func() {
+		It("uses Navidrome artwork URLs by default", func() {
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			Expect(getImageHost()).To(BeNil())
+		})
+
+		It("honors the legacy uguu.se toggle", func() {
+			pdk.PDKMock.On("GetConfig", uguuEnabledKey).Return("true", true)
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			Expect(getImageHost()).To(Equal(uguuHost{}))
+		})
+
+		It("uses the configured host", func() {
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return(imageHostLitterbox, true)
+			Expect(getImageHost()).To(Equal(litterboxHost{}))
+		})
+
+		It("honors the legacy uguu.se toggle with the Navidrome default saved", func() {
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return(imageHostNavidrome, true)
+			pdk.PDKMock.On("GetConfig", uguuEnabledKey).Return("true", true)
+			Expect(getImageHost()).To(Equal(uguuHost{}))
+		})
+
+		It("prefers the selected host over the legacy uguu.se toggle", func() {
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return(imageHostImgur, true)
+			pdk.PDKMock.On("GetConfig", imgurClientIDKey).Return("abc", true)
+			Expect(getImageHost()).To(Equal(imgurHost{ClientID: "abc"}))
+			pdk.PDKMock.AssertNotCalled(GinkgoT(), "GetConfig", uguuEnabledKey)
+		})
+
+		It("defaults the 0x0.st instance", func() {
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return(imageHostNullPointer, true)
+			pdk.PDKMock.On("GetConfig", nullPointerURLKey).Return("", false)
+			Expect(getImageHost()).To(Equal(nullPointerHost{BaseURL: defaultNullPointerURL}))
+		})
+
+		It("uses a custom 0x0.st instance", func() {
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return(imageHostNullPointer, true)
+			pdk.PDKMock.On("GetConfig", nullPointerURLKey).Return("https://envs.sh/", true)
+			Expect(getImageHost()).To(Equal(nullPointerHost{BaseURL: "https://envs.sh"}))
+		})
+
+		It("needs an Imgur client ID", func() {
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return(imageHostImgur, true)
+			pdk.PDKMock.On("GetConfig", imgurClientIDKey).Return("", false)
+			Expect(getImageHost()).To(BeNil())
+		})
+
+		It("falls back to Navidrome artwork URLs for unknown hosts", func() {
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return("Dropbox", true)
+			Expect(getImageHost()).To(BeNil())
+		})
+	})
+
+	Describe("artworkCacheTTL", func() {
+		It("reuses temporary uploads for 5/6 of their retention", func() {
+			Expect(artworkCacheTTL(uguuRetention)).To(Equal(int64(9000)))
+		})
+
+		It("caches kept uploads like resolved links", func() {
+			Expect(artworkCacheTTL(0)).To(Equal(linkCacheTTLHit))
+		})
+	})
+
+	Describe("multipartBody", func() {
+		It("writes the fields before the file, named after its content type", func() {
+			body := string(multipartBody([]formField{{Name: "reqtype", Value: "fileupload"}}, "file", []byte("data"), "image/png"))
+			Expect(body).To(Equal("--" + multipartBoundary + "\r\n" +
+				"Content-Disposition: form-data; name=\"reqtype\"\r\n\r\nfileupload\r\n" +
+				"--" + multipartBoundary + "\r\n" +
+				"Content-Disposition: form-data; name=\"file\"; filename=\"cover.png\"\r\n" +
+				"Content-Type: image/png\r\n\r\ndata\r\n" +
+				"--" + multipartBoundary + "--\r\n"))
+		})
+	})
+
+	Describe("imageExtension", func() {
+		It("maps content types to extensions", func() {
+			Expect(imageExtension("image/png")).To(Equal(".png"))
+			Expect(imageExtension("image/webp; charset=binary")).To(Equal(".webp"))
+			Expect(imageExtension("application/octet-stream")).To(Equal(".jpg"))
+		})
+	})
+
+	Describe("uguuHost", func() {
+		It("returns the URL with uguu.se's retention", func() {
+			uploadTo("https://uguu.se/upload", &host.HTTPResponse{StatusCode: 200, Body: []byte(`{"success":true,"files":[{"url":"https://a.uguu.se/x.jpg"}]}`)})
+			upload, err := uguuHost{}.Upload([]byte("data"), "image/jpeg")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(upload).To(Equal(imageUpload{URL: "https://a.uguu.se/x.jpg", Retention: uguuRetention}))
+		})
+
+		It("returns an error when the upload is not successful", func() {
+			uploadTo("https://uguu.se/upload", &host.HTTPResponse{StatusCode: 200, Body: []byte(`{"success":false}`)})
+			_, err := uguuHost{}.Upload([]byte("data"), "image/jpeg")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("catboxHost", func() {
+		It("returns the plain text URL, kept by the host", func() {
+			sent := uploadTo("https://catbox.moe/user/api.php", &host.HTTPResponse{StatusCode: 200, Body: []byte("https://files.catbox.moe/x.jpg\n")})
+			upload, err := catboxHost{}.Upload([]byte("data"), "image/jpeg")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(upload).To(Equal(imageUpload{URL: "https://files.catbox.moe/x.jpg"}))
+			Expect(string(sent.Body)).To(ContainSubstring(`name="fileToUpload"`))
+		})
+
+		It("returns an error for unexpected responses", func() {
+			uploadTo("https://catbox.moe/user/api.php", &host.HTTPResponse{StatusCode: 200, Body: []byte("No files given")})
+			_, err := catboxHost{}.Upload([]byte("data"), "image/jpeg")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("litterboxHost", func() {
+		It("asks for the longest retention", func() {
+			sent := uploadTo("https://litterbox.catbox.moe/resources/internals/api.php", &host.HTTPResponse{StatusCode: 200, Body: []byte("https://litter.catbox.moe/x.jpg")})
+			upload, err := litterboxHost{}.Upload([]byte("data"), "image/jpeg")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(upload).To(Equal(imageUpload{URL: "https://litter.catbox.moe/x.jpg", Retention: litterboxRetention}))
+			Expect(string(sent.Body)).To(ContainSubstring("name=\"time\"\r\n\r\n72h"))
+		})
+	})
+
+	Describe("nullPointerHost", func() {
+		h := nullPointerHost{BaseURL: "https://0x0.st"}
+
+		It("reads the retention from X-Expires", func() {
+			expires := time.Now().Add(200 * 24 * time.Hour).UnixMilli()
+			uploadTo("https://0x0.st/", &host.HTTPResponse{
+				StatusCode: 200,
+				Headers:    map[string]string{"x-expires": strconv.FormatInt(expires, 10)},
+				Body:       []byte("https://0x0.st/abc.jpg\n"),
+			})
+			upload, err := h.Upload([]byte("data"), "image/jpeg")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(upload.URL).To(Equal("https://0x0.st/abc.jpg"))
+			Expect(upload.Retention).To(BeNumerically("~", 200*24*60*60, 60))
+		})
+
+		It("assumes the minimum retention without X-Expires", func() {
+			uploadTo("https://0x0.st/", &host.HTTPResponse{StatusCode: 200, Body: []byte("https://0x0.st/abc.jpg")})
+			upload, err := h.Upload([]byte("data"), "image/jpeg")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(upload.Retention).To(Equal(nullPointerRetention))
+		})
+	})
+
+	Describe("imgurHost", func() {
+		It("authenticates with the client ID", func() {
+			sent := uploadTo("https://api.imgur.com/3/image", &host.HTTPResponse{StatusCode: 200, Body: []byte(`{"success":true,"data":{"link":"https://i.imgur.com/x.jpg"}}`)})
+			upload, err := imgurHost{ClientID: "abc"}.Upload([]byte("data"), "image/jpeg")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(upload).To(Equal(imageUpload{URL: "https://i.imgur.com/x.jpg"}))
+			Expect(sent.Headers["Authorization"]).To(Equal("Client-ID abc"))
+			Expect(strings.HasPrefix(sent.Headers["Content-Type"], "multipart/form-data")).To(BeTrue())
+		})
+
+		It("returns an error on HTTP errors", func() {
+			uploadTo("https://api.imgur.com/3/image", &host.HTTPResponse{StatusCode: 429})
+			_, err := imgurHost{ClientID: "abc"}.Upload([]byte("data"), "image/jpeg")
+			Expect(err).To(MatchError(ContainSubstring("HTTP 429")))
+		})
+	})
+})
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Register decoders for the formats Navidrome may serve
+	"image/jpeg"
+	_ "image/png"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Configuration keys for artwork processing
+const (
+	artworkSizeKey    = "artworksize"
+	artworkQualityKey = "artworkquality"
+)
+
+const (
+	defaultArtworkSize    = 300
+	minArtworkSize        = 64
+	maxArtworkSize        = 1024
+	defaultArtworkQuality = 85
+)
+
+// getArtworkSize returns the configured side of uploaded artwork in pixels.
+func getArtworkSize() int {
+	return configInt(artworkSizeKey, defaultArtworkSize, minArtworkSize, maxArtworkSize)
+}
+
+// getArtworkQuality returns the configured JPEG quality of uploaded artwork.
+func getArtworkQuality() int {
+	return configInt(artworkQualityKey, defaultArtworkQuality, 1, 100)
+}
+
+// prepareArtwork turns artwork into what is uploaded: decoded, cropped to a centered
+// square, scaled down to size pixels and re-encoded as JPEG at quality. Transparent areas
+// are flattened onto white. Artwork that can't be decoded is returned unchanged, with its
+// content type. Everything here is pure Go, so it also runs in the TinyGo build.
+func prepareArtwork(data []byte, contentType string, size, quality int) ([]byte, string) {
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Uploading artwork as is, failed to decode %s: %v", contentType, err))
+		return data, contentType
+	}
+
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2))
+	size = min(size, side)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	if size == side {
+		draw.Draw(dst, dst.Bounds(), src, crop.Min, draw.Over)
+	} else {
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Over, nil)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: quality}); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Uploading artwork as is, failed to encode JPEG: %v", err))
+		return data, contentType
+	}
+	pdk.Log(pdk.LogDebug, fmt.Sprintf("Prepared artwork: %s %dx%d (%d bytes) → JPEG %dx%d (%d bytes)",
+		format, b.Dx(), b.Dy(), len(data), size, size, buf.Len()))
+	return buf.Bytes(), "image/jpeg"
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// encodePNG returns a w×h PNG, red on the left half and transparent on the right.
+func encodePNG(w, h int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w/2; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	Expect(png.Encode(&buf, img)).To(Succeed())
+	return buf.Bytes()
+}
+
+var _ = Describe("Artwork processing", func() {
+	BeforeEach(func() {
+		pdk.ResetMock()
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	Describe("prepareArtwork", func() {
+		It("crops, scales down and re-encodes as JPEG", func() {
+			data, contentType := prepareArtwork(encodePNG(400, 300), "image/png", 100, 85)
+			Expect(contentType).To(Equal("image/jpeg"))
+			img, format, err := image.Decode(bytes.NewReader(data))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(format).To(Equal("jpeg"))
+			Expect(img.Bounds().Size()).To(Equal(image.Pt(100, 100)))
+		})
+
+		It("crops to the center and flattens transparency onto white", func() {
+			data, _ := prepareArtwork(encodePNG(400, 200), "image/png", 300, 95)
+			img, err := jpeg.Decode(bytes.NewReader(data))
+			Expect(err).ToNot(HaveOccurred())
+			// The 200px square keeps x 100-300 of the original: red, then transparent
+			Expect(img.Bounds().Size()).To(Equal(image.Pt(200, 200)))
+			r, g, _, _ := img.At(20, 100).RGBA()
+			Expect(r >> 8).To(BeNumerically(">", 240))
+			Expect(g >> 8).To(BeNumerically("<", 20))
+			r, g, _, _ = img.At(180, 100).RGBA()
+			Expect(r >> 8).To(BeNumerically(">", 240))
+			Expect(g >> 8).To(BeNumerically(">", 240))
+		})
+
+		It("returns artwork it can't decode unchanged", func() {
+			data, contentType := prepareArtwork([]byte("not an image"), "image/avif", 300, 85)
+			Expect(string(data)).To(Equal("not an image"))
+			Expect(contentType).To(Equal("image/avif"))
+		})
+	})
+
+	Describe("getArtworkSize", func() {
+		It("defaults to 300px", func() {
+			pdk.PDKMock.On("GetConfig", artworkSizeKey).Return("", false)
+			Expect(getArtworkSize()).To(Equal(defaultArtworkSize))
+		})
+
+		It("clamps the configured size", func() {
+			pdk.PDKMock.On("GetConfig", artworkSizeKey).Return("4096", true)
+			Expect(getArtworkSize()).To(Equal(maxArtworkSize))
+		})
+
+		It("ignores invalid values", func() {
+			pdk.PDKMock.On("GetConfig", artworkSizeKey).Return("large", true)
+			Expect(getArtworkSize()).To(Equal(defaultArtworkSize))
+		})
+	})
+
+	Describe("getArtworkQuality", func() {
+		It("uses the configured quality", func() {
+			pdk.PDKMock.On("GetConfig", artworkQualityKey).Return(" 70 ", true)
+			Expect(getArtworkQuality()).To(Equal(70))
+		})
+	})
+})
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// Discord rejects the whole presence update when any activity field is out of bounds.
+// Text lengths are counted in characters, so truncation happens on rune boundaries.
+const (
+	maxTextLength = 128 // name, details, state, large_text, small_text
+	minTextLength = 2
+	maxURLLength  = 256 // details_url, state_url, large_url, small_url
+
+	ellipsis = "…"
+	// textPadding (zero-width space) is appended to non-empty text shorter than minTextLength.
+	textPadding = "\u200b"
+)
+
+// truncateText shortens s to at most limit runes, replacing the tail with an ellipsis.
+func truncateText(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimRight(string(runes[:limit-1]), " ") + ellipsis
+}
+
+// padText pads non-empty strings up to minLength runes. Empty strings are left alone,
+// as Discord treats them as absent.
+func padText(s string, minLength int) string {
+	n := utf8.RuneCountInString(s)
+	if n == 0 || n >= minLength {
+		return s
+	}
+	return s + strings.Repeat(textPadding, minLength-n)
+}
+
+// normalizeText enforces Discord's text field limits, logging any change.
+func normalizeText(username, field, value string) string {
+	normalized := padText(truncateText(strings.TrimSpace(value), maxTextLength), minTextLength)
+	if normalized != value {
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Normalized %s for user %s: %q → %q", field, username, value, normalized))
+	}
+	return normalized
+}
+
+// normalizeURL drops URLs Discord would reject: too long or not http(s).
+func normalizeURL(username, field, value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) > maxURLLength {
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Dropping %s for user %s: %d characters exceeds limit of %d", field, username, len(value), maxURLLength))
+		return ""
+	}
+	if !strings.HasPrefix(value, "https://") && !strings.HasPrefix(value, "http://") {
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Dropping %s for user %s: not an http(s) URL: %q", field, username, value))
+		return ""
+	}
+	return value
+}
+
+// normalizeActivity validates and normalizes all user-visible activity fields so a single
+// oversized value does not cause Discord to reject the whole presence update.
+func normalizeActivity(username string, data activity) activity {
+	data.Name = normalizeText(username, "name", data.Name)
+	data.Details = normalizeText(username, "details", data.Details)
+	data.State = normalizeText(username, "state", data.State)
+	data.Assets.LargeText = normalizeText(username, "large_text", data.Assets.LargeText)
+	data.Assets.SmallText = normalizeText(username, "small_text", data.Assets.SmallText)
+
+	data.DetailsURL = normalizeURL(username, "details_url", data.DetailsURL)
+	data.StateURL = normalizeURL(username, "state_url", data.StateURL)
+	data.Assets.LargeURL = normalizeURL(username, "large_url", data.Assets.LargeURL)
+	data.Assets.SmallURL = normalizeURL(username, "small_url", data.Assets.SmallURL)
+	return data
+}
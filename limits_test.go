@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Discord field limits", func() {
+	BeforeEach(func() {
+		pdk.ResetMock()
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	Describe("truncateText", func() {
+		It("leaves short strings untouched", func() {
+			Expect(truncateText("Karma Police", maxTextLength)).To(Equal("Karma Police"))
+		})
+
+		It("truncates to the limit with an ellipsis", func() {
+			result := truncateText(strings.Repeat("a", 200), maxTextLength)
+			Expect(utf8.RuneCountInString(result)).To(Equal(maxTextLength))
+			Expect(result).To(HaveSuffix(ellipsis))
+		})
+
+		It("cuts on rune boundaries", func() {
+			result := truncateText(strings.Repeat("日本語", 50), maxTextLength)
+			Expect(utf8.ValidString(result)).To(BeTrue())
+			Expect(utf8.RuneCountInString(result)).To(Equal(maxTextLength))
+		})
+
+		It("does not leave a trailing space before the ellipsis", func() {
+			Expect(truncateText("abc defgh", 5)).To(Equal("abc…"))
+		})
+	})
+
+	Describe("padText", func() {
+		DescribeTable("pads short non-empty strings",
+			func(input, expected string) {
+				Expect(padText(input, minTextLength)).To(Equal(expected))
+			},
+			Entry("single character", "X", "X"+textPadding),
+			Entry("empty string", "", ""),
+			Entry("long enough", "XY", "XY"),
+			Entry("single multibyte character", "λ", "λ"+textPadding),
+		)
+	})
+
+	Describe("normalizeURL", func() {
+		DescribeTable("drops invalid URLs",
+			func(input, expected string) {
+				Expect(normalizeURL("testuser", "details_url", input)).To(Equal(expected))
+			},
+			Entry("valid URL", "https://open.spotify.com/track/abc", "https://open.spotify.com/track/abc"),
+			Entry("empty", "", ""),
+			Entry("too long", "https://open.spotify.com/search/"+strings.Repeat("a", 250), ""),
+			Entry("not http", "spotify:track:abc", ""),
+		)
+	})
+
+	Describe("normalizeActivity", func() {
+		It("normalizes all text and URL fields", func() {
+			longTitle := strings.Repeat("Symphony No. 9 ", 20)
+			data := normalizeActivity("testuser", activity{
+				Name:       "Navidrome",
+				Details:    longTitle,
+				State:      "X",
+				DetailsURL: "https://open.spotify.com/search/" + strings.Repeat("a", 300),
+				StateURL:   "https://open.spotify.com/search/X",
+				Assets: activityAssets{
+					LargeText: "  Album  ",
+					SmallText: "Navidrome",
+				},
+			})
+			Expect(data.Name).To(Equal("Navidrome"))
+			Expect(utf8.RuneCountInString(data.Details)).To(Equal(maxTextLength))
+			Expect(data.State).To(Equal("X" + textPadding))
+			Expect(data.DetailsURL).To(BeEmpty())
+			Expect(data.StateURL).To(Equal("https://open.spotify.com/search/X"))
+			Expect(data.Assets.LargeText).To(Equal("Album"))
+			Expect(data.Assets.SmallText).To(Equal("Navidrome"))
+		})
+	})
+})
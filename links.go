@@ -0,0 +1,529 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+)
+
+// Configuration key for the per-user link service preferences
+const linkServicesKey = "linkservices"
+
+// Link service names, as used in the link service preferences
+const (
+	linkServiceSpotify      = "Spotify"
+	linkServiceAppleMusic   = "AppleMusic"
+	linkServiceDeezer       = "Deezer"
+	linkServiceTidal        = "Tidal"
+	linkServiceYouTubeMusic = "YouTubeMusic"
+	linkServiceBandcamp     = "Bandcamp"
+	linkServiceMusicBrainz  = "MusicBrainz"
+	linkServiceLastFM       = "LastFM"
+)
+
+const (
+	linkCacheTTLHit         int64 = 30 * 24 * 60 * 60 // 30 days for resolved track links
+	linkCacheTTLMiss        int64 = 4 * 60 * 60       // 4 hours for misses (retry later)
+	linkCacheTTLUnavailable int64 = 5 * 60            // 5 minutes for fallbacks while the service is failing
+)
+
+// LinkResolver turns a track into links to a music service.
+type LinkResolver interface {
+	// Name identifies the service in the link preferences and cache keys.
+	Name() string
+	// TrackURL returns a direct link to the track, or "" when none is found. It returns an
+	// error when the lookup failed, and errServiceUnavailable when the service is skipped
+	// because it is failing.
+	TrackURL(track scrobbler.TrackInfo) (string, error)
+	// SearchURL builds a track search link from the given terms, or "" when all are empty.
+	SearchURL(terms ...string) string
+	// ArtistURL returns a link to the artist, given its display name.
+	ArtistURL(track scrobbler.TrackInfo, artist string) string
+	// AlbumURL returns a link to the track's album, or "" to link the album art to the track.
+	AlbumURL(track scrobbler.TrackInfo) string
+}
+
+// localLinkResolver is implemented by resolvers that build track links from the tags, without
+// a request. Their links are cheap to build again, so they are only cached, leaving the
+// resolution store to results that needed a lookup.
+type localLinkResolver interface {
+	localLinks()
+}
+
+// linkResolvers lists the available resolvers by service name.
+var linkResolvers = map[string]LinkResolver{
+	linkServiceSpotify:      spotifyResolver{},
+	linkServiceAppleMusic:   appleMusicResolver{},
+	linkServiceDeezer:       deezerResolver{},
+	linkServiceTidal:        tidalResolver{},
+	linkServiceYouTubeMusic: youTubeMusicResolver{},
+	linkServiceBandcamp:     bandcampResolver{},
+	linkServiceMusicBrainz:  musicBrainzResolver{},
+	linkServiceLastFM:       lastFMResolver{},
+}
+
+// linkPreference is a user's ordered list of link services.
+type linkPreference struct {
+	Username string   `json:"username"`
+	Services []string `json:"services"`
+}
+
+// presenceLinks holds the URLs behind the clickable parts of the presence.
+type presenceLinks struct {
+	Track  string // Details line
+	Artist string // State line
+	Album  string // Album art
+}
+
+// getLinkResolvers returns the user's link resolvers in order of preference. Users without
+// preferences get Spotify when Spotify link-through is enabled, and no links otherwise.
+func getLinkResolvers(username string) []LinkResolver {
+	if prefsJSON, ok := pdk.GetConfig(linkServicesKey); ok && prefsJSON != "" {
+		var prefs []linkPreference
+		if err := json.Unmarshal([]byte(prefsJSON), &prefs); err != nil {
+			pdk.Log(pdk.LogError, fmt.Sprintf("failed to parse link services config: %v", err))
+		}
+		for _, p := range prefs {
+			if p.Username != username {
+				continue
+			}
+			var resolvers []LinkResolver
+			for _, name := range p.Services {
+				r, ok := linkResolvers[name]
+				if !ok {
+					pdk.Log(pdk.LogWarn, fmt.Sprintf("Unknown link service %q for user %s", name, username))
+					continue
+				}
+				resolvers = append(resolvers, r)
+			}
+			return resolvers
+		}
+	}
+
+	if spotifyLinks, _ := pdk.GetConfig(spotifyLinksKey); spotifyLinks == "true" {
+		return []LinkResolver{spotifyResolver{}}
+	}
+	return nil
+}
+
+// linkCacheKey returns a deterministic cache key for a track's link on a service. Tags are
+// compared in their folded form, so case and typographic differences share an entry.
+func linkCacheKey(service, artist, title, album string) string {
+	return strings.ToLower(service) + ".url." + hashKey(foldMetadata(artist)+"\x00"+foldMetadata(title)+"\x00"+foldMetadata(album))
+}
+
+// resolveTrackLink returns the resolver's direct link for the track, falling back to a
+// search link. direct reports whether the link points at the track itself. Direct links
+// and search fallbacks are cached with different TTLs, so misses are retried sooner, and
+// fallbacks after a failed lookup sooner still. Direct links that needed a lookup are also
+// persisted in the resolution store.
+func resolveTrackLink(r LinkResolver, track scrobbler.TrackInfo, artist string) (link string, direct bool) {
+	cacheKey := linkCacheKey(r.Name(), artist, track.Title, track.Album)
+	searchURL := r.SearchURL(artist, track.Title)
+
+	if cached, exists := tieredGet(cacheKey, linkCacheTTLHit); exists {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("%s URL cache hit for %q - %q → %s", r.Name(), artist, track.Title, cached))
+		return cached, cached != searchURL
+	}
+
+	directURL, err := r.TrackURL(track)
+	if directURL != "" {
+		if _, local := r.(localLinkResolver); local {
+			_ = host.CacheSetString(cacheKey, directURL, linkCacheTTLHit)
+		} else {
+			tieredSet(cacheKey, directURL, linkCacheTTLHit, 0)
+		}
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Resolved %s link for %q: %s", r.Name(), track.Title, directURL))
+		return directURL, true
+	}
+	if err != nil {
+		_ = host.CacheSetString(cacheKey, searchURL, linkCacheTTLUnavailable)
+		if errors.Is(err, errServiceUnavailable) {
+			pdk.Log(pdk.LogInfo, fmt.Sprintf("%s lookups are unavailable, falling back to search URL for %q - %q: %s", r.Name(), artist, track.Title, searchURL))
+		} else {
+			pdk.Log(pdk.LogInfo, fmt.Sprintf("%s lookup failed, falling back to search URL for %q - %q: %v", r.Name(), artist, track.Title, err))
+		}
+		return searchURL, false
+	}
+
+	_ = host.CacheSetString(cacheKey, searchURL, linkCacheTTLMiss)
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("%s resolution missed, falling back to search URL for %q - %q: %s", r.Name(), artist, track.Title, searchURL))
+	return searchURL, false
+}
+
+// resolveLinks picks the links for the presence from the user's preferred services. The
+// first service with a direct track link wins; when none has one, the first service's
+// search links are used.
+func resolveLinks(username string, track scrobbler.TrackInfo, artist string) presenceLinks {
+	var links presenceLinks
+	for i, r := range getLinkResolvers(username) {
+		link, direct := resolveTrackLink(r, track, artist)
+		if i == 0 || direct {
+			links = presenceLinks{
+				Track:  link,
+				Artist: r.ArtistURL(track, artist),
+				Album:  link,
+			}
+			if albumURL := r.AlbumURL(track); albumURL != "" {
+				links.Album = albumURL
+			}
+		}
+		if direct {
+			break
+		}
+	}
+	return links
+}
+
+// cachedLookup returns the value cached under cacheKey, or calls lookup and caches its result.
+// Found values are cached with the hit TTL and persisted, and misses cached as "" with the
+// miss TTL. Failed lookups are cached as "" only briefly, so they are retried soon.
+func cachedLookup(cacheKey string, lookup func() (string, error)) string {
+	return runCachedLookup(cacheKey, true, lookup)
+}
+
+// cacheOnlyLookup is cachedLookup for values that are cheap to look up again, which are
+// kept out of the resolution store.
+func cacheOnlyLookup(cacheKey string, lookup func() (string, error)) string {
+	return runCachedLookup(cacheKey, false, lookup)
+}
+
+func runCachedLookup(cacheKey string, persist bool, lookup func() (string, error)) string {
+	if persist {
+		if cached, exists := tieredGet(cacheKey, linkCacheTTLHit); exists {
+			return cached
+		}
+	} else if cached, exists, err := host.CacheGetString(cacheKey); err == nil && exists {
+		return cached
+	}
+	value, err := lookup()
+	if err != nil {
+		_ = host.CacheSetString(cacheKey, "", linkCacheTTLUnavailable)
+		return ""
+	}
+	switch {
+	case value == "":
+		_ = host.CacheSetString(cacheKey, value, linkCacheTTLMiss)
+	case persist:
+		tieredSet(cacheKey, value, linkCacheTTLHit, 0)
+	default:
+		_ = host.CacheSetString(cacheKey, value, linkCacheTTLHit)
+	}
+	return value
+}
+
+// searchTerms joins non-empty terms with spaces.
+func searchTerms(terms ...string) string {
+	return strings.TrimSpace(strings.Join(terms, " "))
+}
+
+// fetchJSON sends a GET request and decodes the JSON response into v.
+func fetchJSON(reqURL string, headers map[string]string, v any) error {
+	resp, err := host.HTTPSend(host.HTTPRequest{Method: "GET", URL: reqURL, Headers: headers})
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(resp.Body, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// ============================================================================
+// Apple Music
+// ============================================================================
+
+type appleMusicResolver struct{}
+
+// iTunesSearchResponse captures the relevant fields of an iTunes Search API response.
+type iTunesSearchResponse struct {
+	Results []struct {
+		ArtistName   string `json:"artistName"`
+		TrackName    string `json:"trackName"`
+		TrackViewURL string `json:"trackViewUrl"`
+	} `json:"results"`
+}
+
+func (appleMusicResolver) Name() string { return linkServiceAppleMusic }
+
+// TrackURL looks the track up with the iTunes Search API, accepting only a result by the same artist.
+func (appleMusicResolver) TrackURL(track scrobbler.TrackInfo) (string, error) {
+	primary := primaryArtist(track)
+	if primary == "" || track.Title == "" {
+		return "", nil
+	}
+	var resp iTunesSearchResponse
+	reqURL := "https://itunes.apple.com/search?media=music&entity=song&limit=5&term=" + url.QueryEscape(searchTerms(primary, track.Title))
+	if err := fetchJSON(reqURL, nil, &resp); err != nil {
+		return "", fmt.Errorf("search failed: %w", err)
+	}
+	for _, r := range resp.Results {
+		if r.TrackViewURL != "" && strings.EqualFold(r.ArtistName, primary) {
+			return r.TrackViewURL, nil
+		}
+	}
+	return "", nil
+}
+
+func (appleMusicResolver) SearchURL(terms ...string) string {
+	if q := searchTerms(terms...); q != "" {
+		return "https://music.apple.com/search?term=" + url.QueryEscape(q)
+	}
+	return ""
+}
+
+func (r appleMusicResolver) ArtistURL(_ scrobbler.TrackInfo, artist string) string {
+	return r.SearchURL(artist)
+}
+
+func (appleMusicResolver) AlbumURL(_ scrobbler.TrackInfo) string { return "" }
+
+// ============================================================================
+// Deezer
+// ============================================================================
+
+type deezerResolver struct{}
+
+// deezerSearchResponse captures the relevant fields of a Deezer search API response.
+type deezerSearchResponse struct {
+	Data []struct {
+		Link   string `json:"link"`
+		Artist struct {
+			Name string `json:"name"`
+		} `json:"artist"`
+	} `json:"data"`
+}
+
+func (deezerResolver) Name() string { return linkServiceDeezer }
+
+// TrackURL looks the track up with the Deezer search API, accepting only a result by the
+// same artist. The search is fuzzy, so its top hit may be a cover or a tribute.
+func (deezerResolver) TrackURL(track scrobbler.TrackInfo) (string, error) {
+	primary := primaryArtist(track)
+	if primary == "" || track.Title == "" {
+		return "", nil
+	}
+	var resp deezerSearchResponse
+	query := fmt.Sprintf("artist:%q track:%q", primary, track.Title)
+	if err := fetchJSON("https://api.deezer.com/search/track?limit=5&q="+url.QueryEscape(query), nil, &resp); err != nil {
+		return "", fmt.Errorf("search failed: %w", err)
+	}
+	for _, r := range resp.Data {
+		if r.Link != "" && foldMetadata(r.Artist.Name) == foldMetadata(primary) {
+			return r.Link, nil
+		}
+	}
+	return "", nil
+}
+
+func (deezerResolver) SearchURL(terms ...string) string {
+	if q := searchTerms(terms...); q != "" {
+		return "https://www.deezer.com/search/" + url.PathEscape(q)
+	}
+	return ""
+}
+
+func (deezerResolver) ArtistURL(_ scrobbler.TrackInfo, artist string) string {
+	if q := searchTerms(artist); q != "" {
+		return "https://www.deezer.com/search/" + url.PathEscape(q) + "/artist"
+	}
+	return ""
+}
+
+func (deezerResolver) AlbumURL(_ scrobbler.TrackInfo) string { return "" }
+
+// ============================================================================
+// Search-only services
+// ============================================================================
+
+// Tidal, YouTube Music and Bandcamp have no public lookup API, so only search links are built.
+
+type tidalResolver struct{}
+
+func (tidalResolver) Name() string                                   { return linkServiceTidal }
+func (tidalResolver) TrackURL(_ scrobbler.TrackInfo) (string, error) { return "", nil }
+func (tidalResolver) AlbumURL(_ scrobbler.TrackInfo) string          { return "" }
+
+func (tidalResolver) SearchURL(terms ...string) string {
+	if q := searchTerms(terms...); q != "" {
+		return "https://tidal.com/search?q=" + url.QueryEscape(q)
+	}
+	return ""
+}
+
+func (r tidalResolver) ArtistURL(_ scrobbler.TrackInfo, artist string) string {
+	return r.SearchURL(artist)
+}
+
+type youTubeMusicResolver struct{}
+
+func (youTubeMusicResolver) Name() string                                   { return linkServiceYouTubeMusic }
+func (youTubeMusicResolver) TrackURL(_ scrobbler.TrackInfo) (string, error) { return "", nil }
+func (youTubeMusicResolver) AlbumURL(_ scrobbler.TrackInfo) string          { return "" }
+
+func (youTubeMusicResolver) SearchURL(terms ...string) string {
+	if q := searchTerms(terms...); q != "" {
+		return "https://music.youtube.com/search?q=" + url.QueryEscape(q)
+	}
+	return ""
+}
+
+func (r youTubeMusicResolver) ArtistURL(_ scrobbler.TrackInfo, artist string) string {
+	return r.SearchURL(artist)
+}
+
+type bandcampResolver struct{}
+
+func (bandcampResolver) Name() string                                   { return linkServiceBandcamp }
+func (bandcampResolver) TrackURL(_ scrobbler.TrackInfo) (string, error) { return "", nil }
+func (bandcampResolver) AlbumURL(_ scrobbler.TrackInfo) string          { return "" }
+
+func (bandcampResolver) SearchURL(terms ...string) string {
+	if q := searchTerms(terms...); q != "" {
+		return "https://bandcamp.com/search?item_type=t&q=" + url.QueryEscape(q)
+	}
+	return ""
+}
+
+func (bandcampResolver) ArtistURL(_ scrobbler.TrackInfo, artist string) string {
+	if q := searchTerms(artist); q != "" {
+		return "https://bandcamp.com/search?item_type=b&q=" + url.QueryEscape(q)
+	}
+	return ""
+}
+
+// ============================================================================
+// MusicBrainz
+// ============================================================================
+
+// musicBrainzResolver links MusicBrainz pages built from the track's MBIDs, without any
+// network call. Missing MBIDs fall back to MusicBrainz searches.
+type musicBrainzResolver struct{}
+
+func (musicBrainzResolver) Name() string { return linkServiceMusicBrainz }
+func (musicBrainzResolver) localLinks()  {}
+
+// TrackURL links the recording page when the track has a recording MBID.
+func (musicBrainzResolver) TrackURL(track scrobbler.TrackInfo) (string, error) {
+	return musicBrainzURL("recording", track.MBZRecordingID), nil
+}
+
+func (musicBrainzResolver) SearchURL(terms ...string) string {
+	return musicBrainzSearchURL("recording", terms...)
+}
+
+// ArtistURL links the primary artist's page, falling back to an artist search.
+func (musicBrainzResolver) ArtistURL(track scrobbler.TrackInfo, artist string) string {
+	if len(track.Artists) > 0 {
+		if link := musicBrainzURL("artist", track.Artists[0].MBID); link != "" {
+			return link
+		}
+	}
+	return musicBrainzSearchURL("artist", artist)
+}
+
+// AlbumURL links the release page, then the release group page, falling back to a release search.
+func (musicBrainzResolver) AlbumURL(track scrobbler.TrackInfo) string {
+	if link := musicBrainzURL("release", track.MBZAlbumID); link != "" {
+		return link
+	}
+	if link := musicBrainzURL("release-group", track.MBZReleaseGroupID); link != "" {
+		return link
+	}
+	albumArtist := track.AlbumArtist
+	if albumArtist == "" {
+		albumArtist = primaryArtist(track)
+	}
+	return musicBrainzSearchURL("release", albumArtist, track.Album)
+}
+
+// musicBrainzURL returns the musicbrainz.org page of an entity, or "" when the MBID is not valid.
+func musicBrainzURL(entity, mbid string) string {
+	if !isValidMBID(mbid) {
+		return ""
+	}
+	return "https://musicbrainz.org/" + entity + "/" + strings.ToLower(mbid)
+}
+
+// musicBrainzSearchURL builds a musicbrainz.org search URL for an entity type.
+func musicBrainzSearchURL(entity string, terms ...string) string {
+	if q := searchTerms(terms...); q != "" {
+		return "https://musicbrainz.org/search?type=" + entity + "&query=" + url.QueryEscape(q)
+	}
+	return ""
+}
+
+// isValidMBID checks that id is a MusicBrainz identifier, a UUID in its canonical 8-4-4-4-12 form.
+func isValidMBID(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ============================================================================
+// Last.fm
+// ============================================================================
+
+type lastFMResolver struct{}
+
+func (lastFMResolver) Name() string { return linkServiceLastFM }
+func (lastFMResolver) localLinks()  {}
+
+// TrackURL builds the track page URL from the primary artist and title.
+func (lastFMResolver) TrackURL(track scrobbler.TrackInfo) (string, error) {
+	primary := primaryArtist(track)
+	if primary == "" || track.Title == "" {
+		return "", nil
+	}
+	return "https://www.last.fm/music/" + url.QueryEscape(primary) + "/_/" + url.QueryEscape(track.Title), nil
+}
+
+func (lastFMResolver) SearchURL(terms ...string) string {
+	if q := searchTerms(terms...); q != "" {
+		return "https://www.last.fm/search/tracks?q=" + url.QueryEscape(q)
+	}
+	return ""
+}
+
+// ArtistURL links the artist page of the primary artist.
+func (lastFMResolver) ArtistURL(track scrobbler.TrackInfo, _ string) string {
+	if primary := primaryArtist(track); primary != "" {
+		return "https://www.last.fm/music/" + url.QueryEscape(primary)
+	}
+	return ""
+}
+
+// AlbumURL links the album page of the album artist, or the primary artist when there is none.
+func (lastFMResolver) AlbumURL(track scrobbler.TrackInfo) string {
+	albumArtist := track.AlbumArtist
+	if albumArtist == "" {
+		albumArtist = primaryArtist(track)
+	}
+	if albumArtist == "" || track.Album == "" {
+		return ""
+	}
+	return "https://www.last.fm/music/" + url.QueryEscape(albumArtist) + "/" + url.QueryEscape(track.Album)
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Links", func() {
+	track := scrobbler.TrackInfo{
+		Title:   "Karma Police",
+		Artist:  "Radiohead",
+		Artists: []scrobbler.ArtistRef{{Name: "Radiohead"}},
+		Album:   "OK Computer",
+	}
+
+	keyPrefix := func(prefix string) any {
+		return mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, prefix) })
+	}
+
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.HTTPMock.ExpectedCalls = nil
+		host.HTTPMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	Describe("getLinkResolvers", func() {
+		It("returns the user's services in order", func() {
+			pdk.PDKMock.On("GetConfig", linkServicesKey).Return(`[{"username":"other","services":["Tidal"]},{"username":"testuser","services":["AppleMusic","Unknown","MusicBrainz"]}]`, true)
+
+			resolvers := getLinkResolvers("testuser")
+			Expect(resolvers).To(Equal([]LinkResolver{appleMusicResolver{}, musicBrainzResolver{}}))
+		})
+
+		It("falls back to Spotify when link-through is enabled", func() {
+			pdk.PDKMock.On("GetConfig", linkServicesKey).Return(`[{"username":"other","services":["Tidal"]}]`, true)
+			pdk.PDKMock.On("GetConfig", spotifyLinksKey).Return("true", true)
+
+			Expect(getLinkResolvers("testuser")).To(Equal([]LinkResolver{spotifyResolver{}}))
+		})
+
+		It("returns no resolvers by default", func() {
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+
+			Expect(getLinkResolvers("testuser")).To(BeEmpty())
+		})
+	})
+
+	Describe("linkCacheKey", func() {
+		It("prefixes keys with the service name", func() {
+			Expect(linkCacheKey(linkServiceAppleMusic, "a", "t", "al")).To(HavePrefix("applemusic.url."))
+		})
+
+		It("keeps Spotify keys unchanged", func() {
+			Expect(linkCacheKey(linkServiceSpotify, "a", "t", "al")).To(Equal(spotifyCacheKey("a", "t", "al")))
+		})
+	})
+
+	Describe("resolveTrackLink", func() {
+		It("reports cached search links as not direct", func() {
+			host.CacheMock.On("GetString", keyPrefix("tidal.url.")).Return("https://tidal.com/search?q=Radiohead+Karma+Police", true, nil)
+
+			link, direct := resolveTrackLink(tidalResolver{}, track, "Radiohead")
+			Expect(link).To(Equal("https://tidal.com/search?q=Radiohead+Karma+Police"))
+			Expect(direct).To(BeFalse())
+		})
+
+		It("reports cached track links as direct", func() {
+			host.CacheMock.On("GetString", keyPrefix("lastfm.url.")).Return("https://www.last.fm/music/Radiohead/_/Karma+Police", true, nil)
+
+			_, direct := resolveTrackLink(lastFMResolver{}, track, "Radiohead")
+			Expect(direct).To(BeTrue())
+		})
+
+		It("persists direct links that needed a lookup", func() {
+			host.CacheMock.On("GetString", keyPrefix("deezer.url.")).Return("", false, nil)
+			host.CacheMock.On("SetString", keyPrefix("deezer.url."), mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"data":[
+				{"link":"https://www.deezer.com/track/3135556","artist":{"name":"Radiohead"}}]}`)}, nil)
+
+			_, direct := resolveTrackLink(deezerResolver{}, track, "Radiohead")
+			Expect(direct).To(BeTrue())
+			host.KVStoreMock.AssertCalled(GinkgoT(), "Set", mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, storeKeyPrefix+"deezer.url.") }), mock.Anything)
+		})
+
+		It("only caches links built without a request", func() {
+			host.CacheMock.On("GetString", keyPrefix("lastfm.url.")).Return("", false, nil)
+			host.CacheMock.On("SetString", keyPrefix("lastfm.url."), mock.Anything, mock.Anything).Return(nil)
+
+			link, direct := resolveTrackLink(lastFMResolver{}, track, "Radiohead")
+			Expect(direct).To(BeTrue())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", keyPrefix("lastfm.url."), link, linkCacheTTLHit)
+			host.KVStoreMock.AssertNotCalled(GinkgoT(), "Set", mock.Anything, mock.Anything)
+		})
+
+		It("caches search fallbacks with the miss TTL", func() {
+			host.CacheMock.On("GetString", keyPrefix("youtubemusic.url.")).Return("", false, nil)
+			host.CacheMock.On("SetString", keyPrefix("youtubemusic.url."), mock.Anything, mock.Anything).Return(nil)
+
+			link, direct := resolveTrackLink(youTubeMusicResolver{}, track, "Radiohead")
+			Expect(link).To(Equal("https://music.youtube.com/search?q=Radiohead+Karma+Police"))
+			Expect(direct).To(BeFalse())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", keyPrefix("youtubemusic.url."), link, linkCacheTTLMiss)
+		})
+	})
+
+	Describe("resolveLinks", func() {
+		It("uses the first service with a direct link", func() {
+			pdk.PDKMock.On("GetConfig", linkServicesKey).Return(`[{"username":"testuser","services":["Bandcamp","LastFM","Spotify"]}]`, true)
+			host.CacheMock.On("GetString", mock.Anything).Return("", false, nil)
+			host.CacheMock.On("SetString", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+			links := resolveLinks("testuser", track, "Radiohead")
+			Expect(links.Track).To(Equal("https://www.last.fm/music/Radiohead/_/Karma+Police"))
+			Expect(links.Artist).To(Equal("https://www.last.fm/music/Radiohead"))
+			Expect(links.Album).To(Equal("https://www.last.fm/music/Radiohead/OK+Computer"))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+
+		It("uses the first service's search links when none has a direct link", func() {
+			pdk.PDKMock.On("GetConfig", linkServicesKey).Return(`[{"username":"testuser","services":["Bandcamp","Tidal"]}]`, true)
+			host.CacheMock.On("GetString", mock.Anything).Return("", false, nil)
+			host.CacheMock.On("SetString", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+			links := resolveLinks("testuser", track, "Radiohead")
+			Expect(links.Track).To(Equal("https://bandcamp.com/search?item_type=t&q=Radiohead+Karma+Police"))
+			Expect(links.Artist).To(Equal("https://bandcamp.com/search?item_type=b&q=Radiohead"))
+			Expect(links.Album).To(Equal(links.Track))
+		})
+
+		It("returns no links without preferences", func() {
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+
+			Expect(resolveLinks("testuser", track, "Radiohead")).To(Equal(presenceLinks{}))
+		})
+	})
+
+	Describe("appleMusicResolver", func() {
+		It("returns the first result by the same artist", func() {
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return strings.HasPrefix(req.URL, "https://itunes.apple.com/search?") && strings.Contains(req.URL, "term=Radiohead+Karma+Police")
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"results":[
+				{"artistName":"Some Cover Band","trackViewUrl":"https://music.apple.com/us/album/x/1?i=1"},
+				{"artistName":"radiohead","trackViewUrl":"https://music.apple.com/us/album/ok-computer/2?i=2"}]}`)}, nil)
+
+			Expect(appleMusicResolver{}.TrackURL(track)).To(Equal("https://music.apple.com/us/album/ok-computer/2?i=2"))
+		})
+
+		It("returns an error when the lookup fails", func() {
+			host.HTTPMock.On("Send", mock.Anything).Return((*host.HTTPResponse)(nil), errors.New("timeout"))
+
+			link, err := appleMusicResolver{}.TrackURL(track)
+			Expect(link).To(BeEmpty())
+			Expect(err).To(MatchError(ContainSubstring("timeout")))
+		})
+	})
+
+	Describe("deezerResolver", func() {
+		It("returns the first result by the same artist", func() {
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return strings.HasPrefix(req.URL, "https://api.deezer.com/search/track?limit=5&")
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"data":[
+				{"link":"https://www.deezer.com/track/1","artist":{"name":"Radiohead Tribute Band"}},
+				{"link":"https://www.deezer.com/track/3135556","artist":{"name":"RADIOHEAD"}}]}`)}, nil)
+
+			Expect(deezerResolver{}.TrackURL(track)).To(Equal("https://www.deezer.com/track/3135556"))
+		})
+
+		It("returns nothing when no result is by the artist", func() {
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"data":[
+				{"link":"https://www.deezer.com/track/1","artist":{"name":"Some Cover Band"}}]}`)}, nil)
+
+			Expect(deezerResolver{}.TrackURL(track)).To(BeEmpty())
+		})
+
+		It("returns an error on HTTP errors", func() {
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 500}, nil)
+
+			link, err := deezerResolver{}.TrackURL(track)
+			Expect(link).To(BeEmpty())
+			Expect(err).To(MatchError(ContainSubstring("HTTP 500")))
+		})
+	})
+
+	Describe("musicBrainzResolver", func() {
+		withMBIDs := track
+		withMBIDs.MBZRecordingID = "b1a9c0e9-d987-4042-ae91-78d6a3267d69"
+		withMBIDs.MBZAlbumID = "52709206-8816-3c12-9ff6-f957f2f1eecf"
+		withMBIDs.MBZReleaseGroupID = "b1392450-e666-3926-a536-22c65f834433"
+		withMBIDs.Artists = []scrobbler.ArtistRef{{Name: "Radiohead", MBID: "A74B1B7F-71A5-4011-9441-D0B5E4122711"}}
+
+		It("links the recording page from the MBID", func() {
+			Expect(musicBrainzResolver{}.TrackURL(withMBIDs)).To(Equal("https://musicbrainz.org/recording/b1a9c0e9-d987-4042-ae91-78d6a3267d69"))
+		})
+
+		It("links the artist page from the primary artist's MBID", func() {
+			Expect(musicBrainzResolver{}.ArtistURL(withMBIDs, "Radiohead")).To(Equal("https://musicbrainz.org/artist/a74b1b7f-71a5-4011-9441-d0b5e4122711"))
+		})
+
+		It("links the release page from the album MBID", func() {
+			Expect(musicBrainzResolver{}.AlbumURL(withMBIDs)).To(Equal("https://musicbrainz.org/release/52709206-8816-3c12-9ff6-f957f2f1eecf"))
+		})
+
+		It("links the release group page when the album MBID is missing", func() {
+			noRelease := withMBIDs
+			noRelease.MBZAlbumID = ""
+			Expect(musicBrainzResolver{}.AlbumURL(noRelease)).To(Equal("https://musicbrainz.org/release-group/b1392450-e666-3926-a536-22c65f834433"))
+		})
+
+		It("falls back to searches without MBIDs", func() {
+			Expect(musicBrainzResolver{}.TrackURL(track)).To(BeEmpty())
+			Expect(musicBrainzResolver{}.ArtistURL(track, "Radiohead")).To(Equal("https://musicbrainz.org/search?type=artist&query=Radiohead"))
+			Expect(musicBrainzResolver{}.AlbumURL(track)).To(Equal("https://musicbrainz.org/search?type=release&query=Radiohead+OK+Computer"))
+		})
+
+		It("ignores malformed MBIDs", func() {
+			bad := track
+			bad.MBZRecordingID = "../../admin"
+			Expect(musicBrainzResolver{}.TrackURL(bad)).To(BeEmpty())
+		})
+
+		It("resolves all links without network calls", func() {
+			pdk.PDKMock.On("GetConfig", linkServicesKey).Return(`[{"username":"testuser","services":["MusicBrainz"]}]`, true)
+			host.CacheMock.On("GetString", mock.Anything).Return("", false, nil)
+			host.CacheMock.On("SetString", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+			links := resolveLinks("testuser", withMBIDs, "Radiohead")
+			Expect(links.Track).To(HavePrefix("https://musicbrainz.org/recording/"))
+			Expect(links.Artist).To(HavePrefix("https://musicbrainz.org/artist/"))
+			Expect(links.Album).To(HavePrefix("https://musicbrainz.org/release/"))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+	})
+
+	DescribeTable("isValidMBID",
+		func(id string, expected bool) {
+			Expect(isValidMBID(id)).To(Equal(expected))
+		},
+		Entry("lowercase UUID", "b1a9c0e9-d987-4042-ae91-78d6a3267d69", true),
+		Entry("uppercase UUID", "B1A9C0E9-D987-4042-AE91-78D6A3267D69", true),
+		Entry("empty", "", false),
+		Entry("missing dashes", "b1a9c0e9d9874042ae9178d6a3267d69xxxx", false),
+		Entry("non-hex characters", "g1a9c0e9-d987-4042-ae91-78d6a3267d69", false),
+	)
+
+	DescribeTable("search URLs",
+		func(r LinkResolver, expected string) {
+			Expect(r.SearchURL("Radiohead", "Karma Police")).To(Equal(expected))
+			Expect(r.SearchURL("", "  ")).To(BeEmpty())
+		},
+		Entry("Spotify", spotifyResolver{}, "https://open.spotify.com/search/Radiohead%20Karma%20Police"),
+		Entry("Apple Music", appleMusicResolver{}, "https://music.apple.com/search?term=Radiohead+Karma+Police"),
+		Entry("Deezer", deezerResolver{}, "https://www.deezer.com/search/Radiohead%20Karma%20Police"),
+		Entry("Tidal", tidalResolver{}, "https://tidal.com/search?q=Radiohead+Karma+Police"),
+		Entry("YouTube Music", youTubeMusicResolver{}, "https://music.youtube.com/search?q=Radiohead+Karma+Police"),
+		Entry("Bandcamp", bandcampResolver{}, "https://bandcamp.com/search?item_type=t&q=Radiohead+Karma+Police"),
+		Entry("MusicBrainz", musicBrainzResolver{}, "https://musicbrainz.org/search?type=recording&query=Radiohead+Karma+Police"),
+		Entry("Last.fm", lastFMResolver{}, "https://www.last.fm/search/tracks?q=Radiohead+Karma+Police"),
+	)
+})
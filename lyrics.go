@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// Configuration key for synced lyrics
+const lyricsFieldKey = "lyricsfield"
+
+// Synced lyrics display options: which activity field shows the current line
+const (
+	lyricsFieldNone      = "None"
+	lyricsFieldState     = "State"
+	lyricsFieldDetails   = "Details"
+	lyricsFieldLargeText = "LargeText"
+)
+
+// payloadLyrics routes lyric update callbacks. The schedule ID is "<username>-lyrics".
+const payloadLyrics = "lyrics"
+
+const (
+	// lyricsMinInterval is the minimum time between two lyric updates, in milliseconds.
+	// Lines closer together are merged so updates stay within Discord's presence rate limit.
+	lyricsMinInterval int64 = 5000
+	lyricsCacheTTL    int64 = 24 * 60 * 60 // Parsed lyrics per track
+	lyricsMergeSep          = " / "
+)
+
+// lyricLine is a single synced lyrics line.
+type lyricLine struct {
+	Start int64  `json:"start"` // Milliseconds from the start of the track
+	Value string `json:"value"`
+}
+
+// lyricsSession holds what lyric callbacks need to update the presence. NowPlaying caches it
+// per user for the duration of the track, and each scheduled callback reads it back.
+type lyricsSession struct {
+	TrackID   string      `json:"trackId"`
+	Field     string      `json:"field"`
+	StartTime int64       `json:"startTime"`         // Track start, Unix milliseconds
+	EndTime   int64       `json:"endTime,omitempty"` // Track end, Unix milliseconds, 0 when unknown
+	Lines     []lyricLine `json:"lines"`
+	Shown     int         `json:"shown"` // Index of the line currently shown, -1 for none
+	Activity  activity    `json:"activity"`
+}
+
+// getLyricsField returns the activity field configured for synced lyrics, or "" when disabled.
+func getLyricsField() string {
+	field, _ := pdk.GetConfig(lyricsFieldKey)
+	switch field {
+	case lyricsFieldState, lyricsFieldDetails, lyricsFieldLargeText:
+		return field
+	}
+	return ""
+}
+
+func lyricsScheduleID(username string) string {
+	return fmt.Sprintf("%s-lyrics", username)
+}
+
+func lyricsSessionKey(username string) string {
+	return fmt.Sprintf("lyrics.session.%s", username)
+}
+
+// fetchSyncedLyrics returns the merged synced lyrics lines for a track. Tracks without
+// synced lyrics are cached as well, so they are not looked up on every play.
+func fetchSyncedLyrics(username, trackID string) ([]lyricLine, error) {
+	cacheKey := "lyrics.lines." + trackID
+	if cached, exists, err := host.CacheGetString(cacheKey); err == nil && exists {
+		var lines []lyricLine
+		if err := json.Unmarshal([]byte(cached), &lines); err == nil {
+			pdk.Log(pdk.LogDebug, fmt.Sprintf("Cache hit for lyrics: %s (%d lines)", trackID, len(lines)))
+			return lines, nil
+		}
+	}
+
+	body, err := host.SubsonicAPICall(fmt.Sprintf("/getLyricsBySongId?u=%s&id=%s", url.QueryEscape(username), url.QueryEscape(trackID)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch lyrics: %w", err)
+	}
+	resp, err := parseSubsonicResponse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []lyricLine
+	if resp.Response.LyricsList != nil {
+		lines = mergeLyricLines(syncedLyricLines(resp.Response.LyricsList.StructuredLyrics), lyricsMinInterval)
+	}
+	if data, err := json.Marshal(lines); err == nil {
+		_ = host.CacheSetString(cacheKey, string(data), lyricsCacheTTL)
+	}
+	return lines, nil
+}
+
+// syncedLyricLines returns the lines of the first synced lyrics entry, with the offset
+// applied and sorted by start time.
+func syncedLyricLines(entries []subsonicStructuredLyrics) []lyricLine {
+	for _, entry := range entries {
+		if !entry.Synced || len(entry.Line) == 0 {
+			continue
+		}
+		lines := make([]lyricLine, 0, len(entry.Line))
+		for _, l := range entry.Line {
+			lines = append(lines, lyricLine{
+				Start: max(l.Start-entry.Offset, 0),
+				Value: strings.TrimSpace(l.Value),
+			})
+		}
+		sort.SliceStable(lines, func(i, j int) bool { return lines[i].Start < lines[j].Start })
+		return lines
+	}
+	return nil
+}
+
+// mergeLyricLines merges lines starting less than minGap after the previous one into it,
+// so consecutive updates are always at least minGap apart.
+func mergeLyricLines(lines []lyricLine, minGap int64) []lyricLine {
+	var merged []lyricLine
+	for _, l := range lines {
+		if n := len(merged); n > 0 && l.Start-merged[n-1].Start < minGap {
+			switch {
+			case l.Value == "":
+			case merged[n-1].Value == "":
+				merged[n-1].Value = l.Value
+			default:
+				merged[n-1].Value += lyricsMergeSep + l.Value
+			}
+			continue
+		}
+		merged = append(merged, l)
+	}
+	return merged
+}
+
+// lyricIndexAt returns the index of the line shown at elapsed milliseconds, or -1 before the first line.
+func lyricIndexAt(lines []lyricLine, elapsed int64) int {
+	return sort.Search(len(lines), func(i int) bool { return lines[i].Start > elapsed }) - 1
+}
+
+// startLyricsSession cancels any running lyrics schedule for the user and, when synced lyrics
+// are enabled and available for the track, returns a new session for the given activity.
+func startLyricsSession(username, trackID string, startTime, endTime int64, data activity) *lyricsSession {
+	field := getLyricsField()
+	if field == "" {
+		return nil
+	}
+	_ = host.SchedulerCancelSchedule(lyricsScheduleID(username))
+
+	lines, err := fetchSyncedLyrics(username, trackID)
+	if err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to get synced lyrics for track %s: %v", trackID, err))
+	}
+	if len(lines) == 0 {
+		_ = host.CacheRemove(lyricsSessionKey(username))
+		return nil
+	}
+
+	return &lyricsSession{
+		TrackID:   trackID,
+		Field:     field,
+		StartTime: startTime,
+		EndTime:   endTime,
+		Lines:     lines,
+		Shown:     -1,
+		Activity:  data,
+	}
+}
+
+// activityAt returns the session's activity with the line shown at now (Unix milliseconds)
+// in the configured field, and records that line as shown.
+func (s *lyricsSession) activityAt(now int64) activity {
+	s.Shown = lyricIndexAt(s.Lines, now-s.StartTime)
+	data := s.Activity
+	if s.Shown < 0 || s.Lines[s.Shown].Value == "" {
+		return data
+	}
+	line := s.Lines[s.Shown].Value
+	switch s.Field {
+	case lyricsFieldState:
+		data.State = line
+	case lyricsFieldDetails:
+		data.Details = line
+	case lyricsFieldLargeText:
+		data.Assets.LargeText = line
+	}
+	return data
+}
+
+// scheduleNextLyric stores the session and schedules a callback for the next line, if any.
+func scheduleNextLyric(username string, s *lyricsSession, now int64) {
+	next := s.Shown + 1
+	if next >= len(s.Lines) {
+		_ = host.CacheRemove(lyricsSessionKey(username))
+		return
+	}
+	if s.EndTime > 0 && s.StartTime+s.Lines[next].Start >= s.EndTime {
+		_ = host.CacheRemove(lyricsSessionKey(username))
+		return
+	}
+
+	// Round up, so the callback never fires before the line starts
+	delayMs := s.StartTime + s.Lines[next].Start - now
+	delay := int32((max(delayMs, lyricsMinInterval) + 999) / 1000)
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to marshal lyrics session for user %s: %v", username, err))
+		return
+	}
+	if err := host.CacheSetString(lyricsSessionKey(username), string(data), int64(delay)+60); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to store lyrics session for user %s: %v", username, err))
+		return
+	}
+	if _, err := host.SchedulerScheduleOneTime(delay, payloadLyrics, lyricsScheduleID(username)); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to schedule lyrics update for user %s: %v", username, err))
+	}
+}
+
+// stopLyrics cancels the user's lyrics schedule and drops the cached session.
+func stopLyrics(username string) {
+	_ = host.SchedulerCancelSchedule(lyricsScheduleID(username))
+	_ = host.CacheRemove(lyricsSessionKey(username))
+}
+
+// handleLyricsCallback shows the current lyrics line and schedules the next one.
+func handleLyricsCallback(username string) error {
+	cached, exists, err := host.CacheGetString(lyricsSessionKey(username))
+	if err != nil || !exists {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("No lyrics session for user %s", username))
+		return nil
+	}
+	var session lyricsSession
+	if err := json.Unmarshal([]byte(cached), &session); err != nil {
+		return fmt.Errorf("failed to parse lyrics session: %w", err)
+	}
+	if getLyricsField() == "" {
+		stopLyrics(username)
+		return nil
+	}
+	if inQuietHours(username, time.Now()) {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Quiet hours active for user %s, stopping lyrics", username))
+		stopLyrics(username)
+		return nil
+	}
+
+	clientID, users, err := getConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get config: %w", err)
+	}
+	token, ok := users[username]
+	if !ok {
+		stopLyrics(username)
+		return nil
+	}
+
+	now := time.Now().UnixMilli()
+	shown := session.Shown
+	data := session.activityAt(now)
+	if session.Shown != shown {
+		if err := rpc.sendActivity(clientID, username, token, data); err != nil {
+			return fmt.Errorf("failed to send lyrics update: %w", err)
+		}
+	}
+	scheduleNextLyric(username, &session, now)
+	return nil
+}
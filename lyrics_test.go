@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Synced lyrics", func() {
+	lines := []lyricLine{
+		{Start: 0, Value: ""},
+		{Start: 10000, Value: "First line"},
+		{Start: 20000, Value: "Second line"},
+		{Start: 30000, Value: "Third line"},
+	}
+
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.SchedulerMock.ExpectedCalls = nil
+		host.SchedulerMock.Calls = nil
+		host.SubsonicAPIMock.ExpectedCalls = nil
+		host.SubsonicAPIMock.Calls = nil
+		host.WebSocketMock.ExpectedCalls = nil
+		host.WebSocketMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	Describe("syncedLyricLines", func() {
+		It("uses the first synced entry and applies the offset", func() {
+			var resp subsonicResponse
+			Expect(json.Unmarshal([]byte(`{"subsonic-response":{"status":"ok","lyricsList":{"structuredLyrics":[
+				{"synced":false,"line":[{"value":"Unsynced"}]},
+				{"synced":true,"offset":500,"line":[{"start":2000,"value":" B "},{"start":1000,"value":"A"},{"start":200,"value":"Intro"}]}
+			]}}}`), &resp)).To(Succeed())
+
+			result := syncedLyricLines(resp.Response.LyricsList.StructuredLyrics)
+			Expect(result).To(Equal([]lyricLine{
+				{Start: 0, Value: "Intro"},
+				{Start: 500, Value: "A"},
+				{Start: 1500, Value: "B"},
+			}))
+		})
+
+		It("returns nothing without synced lyrics", func() {
+			Expect(syncedLyricLines([]subsonicStructuredLyrics{{Synced: false}})).To(BeEmpty())
+		})
+	})
+
+	Describe("mergeLyricLines", func() {
+		It("merges lines closer than the minimum gap", func() {
+			merged := mergeLyricLines([]lyricLine{
+				{Start: 0, Value: "A"},
+				{Start: 2000, Value: "B"},
+				{Start: 4000, Value: ""},
+				{Start: 6000, Value: "C"},
+				{Start: 20000, Value: "D"},
+			}, 5000)
+			Expect(merged).To(Equal([]lyricLine{
+				{Start: 0, Value: "A / B"},
+				{Start: 6000, Value: "C"},
+				{Start: 20000, Value: "D"},
+			}))
+		})
+
+		It("fills an empty line with the next merged one", func() {
+			merged := mergeLyricLines([]lyricLine{{Start: 0, Value: ""}, {Start: 1000, Value: "A"}}, 5000)
+			Expect(merged).To(Equal([]lyricLine{{Start: 0, Value: "A"}}))
+		})
+	})
+
+	Describe("lyricIndexAt", func() {
+		DescribeTable("finds the line shown at a given time",
+			func(elapsed int64, expected int) {
+				Expect(lyricIndexAt(lines[1:], elapsed)).To(Equal(expected))
+			},
+			Entry("before the first line", int64(5000), -1),
+			Entry("exactly at a line", int64(10000), 0),
+			Entry("between lines", int64(25000), 1),
+			Entry("after the last line", int64(99000), 2),
+		)
+	})
+
+	Describe("activityAt", func() {
+		It("replaces the configured field with the current line", func() {
+			s := &lyricsSession{Field: lyricsFieldState, StartTime: 1000000, Lines: lines, Shown: -1,
+				Activity: activity{Details: "Song", State: "Artist"}}
+
+			data := s.activityAt(1000000 + 21000)
+			Expect(data.State).To(Equal("Second line"))
+			Expect(data.Details).To(Equal("Song"))
+			Expect(s.Shown).To(Equal(2))
+			Expect(s.Activity.State).To(Equal("Artist"))
+		})
+
+		It("keeps the original text for empty lines", func() {
+			s := &lyricsSession{Field: lyricsFieldDetails, StartTime: 1000000, Lines: lines, Shown: -1,
+				Activity: activity{Details: "Song"}}
+
+			Expect(s.activityAt(1000000 + 500).Details).To(Equal("Song"))
+		})
+	})
+
+	Describe("fetchSyncedLyrics", func() {
+		It("fetches, merges and caches lyrics", func() {
+			host.CacheMock.On("GetString", "lyrics.lines.track1").Return("", false, nil)
+			host.CacheMock.On("SetString", "lyrics.lines.track1", mock.Anything, lyricsCacheTTL).Return(nil)
+			host.SubsonicAPIMock.On("Call", "/getLyricsBySongId?u=testuser&id=track1").
+				Return(`{"subsonic-response":{"status":"ok","lyricsList":{"structuredLyrics":[{"synced":true,"line":[{"start":0,"value":"A"},{"start":1000,"value":"B"},{"start":9000,"value":"C"}]}]}}}`, nil)
+
+			result, err := fetchSyncedLyrics("testuser", "track1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal([]lyricLine{{Start: 0, Value: "A / B"}, {Start: 9000, Value: "C"}}))
+		})
+
+		It("caches tracks without synced lyrics", func() {
+			host.CacheMock.On("GetString", "lyrics.lines.track1").Return("", false, nil)
+			host.CacheMock.On("SetString", "lyrics.lines.track1", "null", lyricsCacheTTL).Return(nil)
+			host.SubsonicAPIMock.On("Call", mock.Anything).
+				Return(`{"subsonic-response":{"status":"ok","lyricsList":{}}}`, nil)
+
+			result, err := fetchSyncedLyrics("testuser", "track1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(BeEmpty())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "lyrics.lines.track1", "null", lyricsCacheTTL)
+		})
+
+		It("uses cached lyrics", func() {
+			host.CacheMock.On("GetString", "lyrics.lines.track1").Return(`[{"start":0,"value":"A"}]`, true, nil)
+
+			result, err := fetchSyncedLyrics("testuser", "track1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(HaveLen(1))
+			host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "Call", mock.Anything)
+		})
+	})
+
+	Describe("startLyricsSession", func() {
+		It("returns nil when lyrics are disabled", func() {
+			pdk.PDKMock.On("GetConfig", lyricsFieldKey).Return("", false)
+			Expect(startLyricsSession("testuser", "track1", 0, 0, activity{})).To(BeNil())
+			host.SchedulerMock.AssertNotCalled(GinkgoT(), "CancelSchedule", mock.Anything)
+		})
+
+		It("cancels the previous schedule and drops the session when there are no lyrics", func() {
+			pdk.PDKMock.On("GetConfig", lyricsFieldKey).Return(lyricsFieldState, true)
+			host.SchedulerMock.On("CancelSchedule", "testuser-lyrics").Return(nil)
+			host.CacheMock.On("GetString", "lyrics.lines.track1").Return("[]", true, nil)
+			host.CacheMock.On("Remove", "lyrics.session.testuser").Return(nil)
+
+			Expect(startLyricsSession("testuser", "track1", 0, 0, activity{})).To(BeNil())
+			host.SchedulerMock.AssertCalled(GinkgoT(), "CancelSchedule", "testuser-lyrics")
+			host.CacheMock.AssertCalled(GinkgoT(), "Remove", "lyrics.session.testuser")
+		})
+	})
+
+	Describe("scheduleNextLyric", func() {
+		It("stores the session and schedules the next line", func() {
+			host.CacheMock.On("SetString", "lyrics.session.testuser", mock.Anything, mock.Anything).Return(nil)
+			host.SchedulerMock.On("ScheduleOneTime", int32(10), payloadLyrics, "testuser-lyrics").Return("testuser-lyrics", nil)
+
+			s := &lyricsSession{Field: lyricsFieldState, StartTime: 1000000, Lines: lines, Shown: 1}
+			scheduleNextLyric("testuser", s, 1000000+10500)
+			host.SchedulerMock.AssertCalled(GinkgoT(), "ScheduleOneTime", int32(10), payloadLyrics, "testuser-lyrics")
+		})
+
+		It("never schedules sooner than the minimum interval", func() {
+			host.CacheMock.On("SetString", "lyrics.session.testuser", mock.Anything, mock.Anything).Return(nil)
+			host.SchedulerMock.On("ScheduleOneTime", int32(lyricsMinInterval/1000), payloadLyrics, "testuser-lyrics").Return("testuser-lyrics", nil)
+
+			s := &lyricsSession{Field: lyricsFieldState, StartTime: 1000000, Lines: lines, Shown: 0}
+			scheduleNextLyric("testuser", s, 1000000+9000)
+			host.SchedulerMock.AssertCalled(GinkgoT(), "ScheduleOneTime", int32(lyricsMinInterval/1000), payloadLyrics, "testuser-lyrics")
+		})
+
+		It("stops after the last line", func() {
+			host.CacheMock.On("Remove", "lyrics.session.testuser").Return(nil)
+
+			s := &lyricsSession{Field: lyricsFieldState, StartTime: 1000000, Lines: lines, Shown: 3}
+			scheduleNextLyric("testuser", s, 1000000+31000)
+			host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("stops at the end of the track", func() {
+			host.CacheMock.On("Remove", "lyrics.session.testuser").Return(nil)
+
+			s := &lyricsSession{Field: lyricsFieldState, StartTime: 1000000, EndTime: 1000000 + 15000, Lines: lines, Shown: 1}
+			scheduleNextLyric("testuser", s, 1000000+11000)
+			host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+		})
+	})
+
+	Describe("handleLyricsCallback", func() {
+		It("does nothing without a session", func() {
+			host.CacheMock.On("GetString", "lyrics.session.testuser").Return("", false, nil)
+
+			Expect(handleLyricsCallback("testuser")).To(Succeed())
+			host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+		})
+
+		It("sends the current line and schedules the next one", func() {
+			start := time.Now().UnixMilli() - 21000
+			session, _ := json.Marshal(lyricsSession{
+				TrackID: "track1", Field: lyricsFieldState, StartTime: start, Lines: lines, Shown: 1,
+				Activity: activity{Name: "Navidrome", Details: "Song", State: "Artist"},
+			})
+			host.CacheMock.On("GetString", "lyrics.session.testuser").Return(string(session), true, nil)
+			pdk.PDKMock.On("GetConfig", lyricsFieldKey).Return(lyricsFieldState, true)
+			pdk.PDKMock.On("GetConfig", quietHoursKey).Return("", false)
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
+
+			host.CacheMock.On("GetString", discordImageKey).Return("mp:cached/art", true, nil)
+			var sentPayload string
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+				sentPayload = args.Get(1).(string)
+			}).Return(nil)
+			host.CacheMock.On("SetString", "lyrics.session.testuser", mock.Anything, mock.Anything).Return(nil)
+			host.SchedulerMock.On("ScheduleOneTime", mock.Anything, payloadLyrics, "testuser-lyrics").Return("testuser-lyrics", nil)
+
+			Expect(handleLyricsCallback("testuser")).To(Succeed())
+			Expect(sentPayload).To(ContainSubstring(`"state":"Second line"`))
+			host.SchedulerMock.AssertCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, payloadLyrics, "testuser-lyrics")
+		})
+
+		It("skips the update when the line has not changed", func() {
+			start := time.Now().UnixMilli() - 21000
+			session, _ := json.Marshal(lyricsSession{
+				TrackID: "track1", Field: lyricsFieldState, StartTime: start, Lines: lines, Shown: 2,
+			})
+			host.CacheMock.On("GetString", "lyrics.session.testuser").Return(string(session), true, nil)
+			pdk.PDKMock.On("GetConfig", lyricsFieldKey).Return(lyricsFieldState, true)
+			pdk.PDKMock.On("GetConfig", quietHoursKey).Return("", false)
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
+			host.CacheMock.On("SetString", "lyrics.session.testuser", mock.MatchedBy(func(s string) bool {
+				return strings.Contains(s, `"shown":2`)
+			}), mock.Anything).Return(nil)
+			host.SchedulerMock.On("ScheduleOneTime", mock.Anything, payloadLyrics, "testuser-lyrics").Return("testuser-lyrics", nil)
+
+			Expect(handleLyricsCallback("testuser")).To(Succeed())
+			host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+		})
+
+		It("stops instead of sending during quiet hours", func() {
+			start := time.Now().UnixMilli() - 21000
+			session, _ := json.Marshal(lyricsSession{
+				TrackID: "track1", Field: lyricsFieldState, StartTime: start, Lines: lines, Shown: 1,
+			})
+			host.CacheMock.On("GetString", "lyrics.session.testuser").Return(string(session), true, nil)
+			pdk.PDKMock.On("GetConfig", lyricsFieldKey).Return(lyricsFieldState, true)
+			pdk.PDKMock.On("GetConfig", quietHoursKey).Return(`[{"username":"testuser","start":"00:00","end":"00:00"}]`, true)
+			host.SchedulerMock.On("CancelSchedule", "testuser-lyrics").Return(nil)
+			host.CacheMock.On("Remove", "lyrics.session.testuser").Return(nil)
+
+			Expect(handleLyricsCallback("testuser")).To(Succeed())
+			host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+			host.SchedulerMock.AssertCalled(GinkgoT(), "CancelSchedule", "testuser-lyrics")
+			host.CacheMock.AssertCalled(GinkgoT(), "Remove", "lyrics.session.testuser")
+			host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+		})
+	})
+})
@@ -11,8 +11,10 @@
 package main
 
 import (
+	_ "embed"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,11 +36,31 @@ const (
 const (
 	navidromeWebsiteURL = "https://www.navidrome.org"
 
-	// navidromeLogoURL is the small overlay image shown in the bottom-right of the album art.
+	// navidromeLogoURL is the default small overlay image shown in the bottom-right of the album art.
 	// The file is stored in the plugins' GitHub repository so Discord can fetch it as an external asset.
 	navidromeLogoURL = "https://raw.githubusercontent.com/navidrome/website/refs/heads/master/assets/icons/logo.webp"
+
+	// defaultBadgeBaseURL is where Discord fetches the audio format badges from, unless the Badge
+	// Base URL option points elsewhere. It serves the badges/ directory of the upstream
+	// repository's master branch, so it only works for badges already merged there: forks and
+	// builds with new badges have to host their own copy.
+	defaultBadgeBaseURL = "https://raw.githubusercontent.com/navidrome/discord-rich-presence-plugin/master/badges/"
 )
 
+// manifestJSON is the plugin manifest, which holds the version set by releases.
+//
+//go:embed manifest.json
+var manifestJSON []byte
+
+// pluginVersion is the plugin version from the manifest.
+var pluginVersion = func() string {
+	var manifest struct {
+		Version string `json:"version"`
+	}
+	_ = json.Unmarshal(manifestJSON, &manifest)
+	return manifest.Version
+}()
+
 // Activity name display options
 const (
 	activityNameDefault = "Default"
@@ -109,6 +131,20 @@ func getConfig() (clientID string, users map[string]string, err error) {
 	return clientID, users, nil
 }
 
+// configInt reads an integer setting, falling back to def when unset or invalid and
+// clamping it to [lo, hi].
+func configInt(key string, def, lo, hi int) int {
+	value, ok := pdk.GetConfig(key)
+	if !ok || value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return def
+	}
+	return min(max(n, lo), hi)
+}
+
 // ============================================================================
 // Scrobbler Implementation
 // ============================================================================
@@ -141,18 +177,32 @@ func (p *discordPlugin) NowPlaying(input scrobbler.NowPlayingRequest) error {
 		return fmt.Errorf("%w: user '%s' not authorized", scrobbler.ScrobblerErrorNotAuthorized, input.Username)
 	}
 
+	// Respect the user's quiet hours, and stop the previous track's lyrics
+	now := time.Now()
+	if inQuietHours(input.Username, now) {
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Quiet hours active for user %s, skipping presence update", input.Username))
+		stopLyrics(input.Username)
+		return nil
+	}
+
 	// Connect to Discord
 	if err := rpc.connect(input.Username, userToken); err != nil {
 		return fmt.Errorf("%w: failed to connect to Discord: %v", scrobbler.ScrobblerErrorRetryLater, err)
 	}
 
-	// Cancel any existing completion schedule
+	// Cancel any existing completion schedule, and the previous activity if it was held back
 	_ = host.SchedulerCancelSchedule(fmt.Sprintf("%s-clear", input.Username))
+	cancelImageRetry(input.Username)
 
-	// Calculate timestamps
-	now := time.Now().Unix()
-	startTime := (now - int64(input.Position)) * 1000
-	endTime := startTime + int64(input.Track.Duration)*1000
+	// Calculate timestamps. The end is unknown for streams without a duration.
+	startTime := (now.Unix() - int64(input.Position)) * 1000
+	var endTime int64
+	if input.Track.Duration > 0 {
+		endTime = startTime + int64(input.Track.Duration)*1000
+	}
+
+	// Format the artist credit based on configuration
+	artist := formatArtists(input.Track)
 
 	// Resolve the activity name based on configuration
 	activityName := "Navidrome"
@@ -166,51 +216,104 @@ func (p *discordPlugin) NowPlaying(input scrobbler.NowPlayingRequest) error {
 		activityName = input.Track.Album
 		statusDisplayType = statusDisplayName
 	case activityNameArtist:
-		activityName = input.Track.Artist
+		activityName = artist
 		statusDisplayType = statusDisplayName
 	}
 
-	// Resolve Spotify URLs if enabled
-	var spotifyURL, artistSearchURL string
-	spotifyLinksOption, _ := pdk.GetConfig(spotifyLinksKey)
-	if spotifyLinksOption == "true" {
-		spotifyURL = resolveSpotifyURL(input.Track)
-		artistSearchURL = spotifySearchURL(input.Track.Artist)
+	// Resolve links from the user's preferred music services
+	links := resolveLinks(input.Username, input.Track, artist)
+
+	// Pick the small image, whose hover text the rating indicator may replace
+	smallImage, smallText := getSmallImage(input.Username, input.Track)
+	if smallText == "" {
+		smallText = "Navidrome"
+	}
+	rating, ratingAsSmallText := getRatingIndicator(input.Username, input.Track.ID)
+	if ratingAsSmallText && rating != "" {
+		smallText = rating
 	}
 
-	// Send activity update
-	if err := rpc.sendActivity(clientID, input.Username, userToken, activity{
+	// Look up the play count badge if it is shown
+	state := artist
+	playCount, playBadge, showPlayBadge := getPlayCountInfo(input.Username, input.Track.ID, int64(input.Track.Duration)-int64(input.Position))
+	if showPlayBadge && playBadge != "" {
+		state = fmt.Sprintf("%s · %s", artist, playBadge)
+	}
+
+	// Look up the next track in the play queue if it is shown
+	largeText := input.Track.Album
+	nextTitle, nextArtist, showUpNext := getUpNext(input.Username, input.Track.ID, int64(input.Track.Duration))
+	if showUpNext && nextTitle != "" {
+		largeText = upNextText(nextTitle, nextArtist)
+	}
+
+	// Look up the audio format if a template shows it
+	audioFormat := ""
+	if templatesUse(placeholderFormat) {
+		audioFormat, _ = getAudioFormat(input.Username, input.Track.ID)
+	}
+
+	// Values available to the text templates
+	placeholders := map[string]string{
+		placeholderTitle:      input.Track.Title,
+		placeholderArtist:     artist,
+		placeholderAlbum:      input.Track.Album,
+		placeholderRating:     rating,
+		placeholderPlayCount:  playCount,
+		placeholderPlayBadge:  playBadge,
+		placeholderNextTitle:  nextTitle,
+		placeholderNextArtist: nextArtist,
+		placeholderFormat:     audioFormat,
+	}
+
+	data := activity{
 		Application:       clientID,
 		Name:              activityName,
 		Type:              2, // Listening
-		Details:           input.Track.Title,
-		DetailsURL:        spotifyURL,
-		State:             input.Track.Artist,
-		StateURL:          artistSearchURL,
+		Details:           applyTemplate(detailsTemplateKey, input.Track.Title, placeholders),
+		DetailsURL:        links.Track,
+		State:             applyTemplate(stateTemplateKey, state, placeholders),
+		StateURL:          links.Artist,
 		StatusDisplayType: statusDisplayType,
-		Timestamps: activityTimestamps{
-			Start: startTime,
-			End:   endTime,
-		},
+		Timestamps:        buildTimestamps(getTimestampMode(), startTime, endTime),
 		Assets: activityAssets{
-			LargeImage: getImageURL(input.Username, input.Track.ID),
-			LargeText:  input.Track.Album,
-			LargeURL:   spotifyURL,
-			SmallImage: navidromeLogoURL,
-			SmallText:  "Navidrome",
+			LargeImage: getImageURL(input.Username, input.Track),
+			LargeText:  applyTemplate(largeTextTemplateKey, largeText, placeholders),
+			LargeURL:   links.Album,
+			SmallImage: smallImage,
+			SmallText:  applyTemplate(smallTextTemplateKey, smallText, placeholders),
 			SmallURL:   navidromeWebsiteURL,
 		},
-	}); err != nil {
+	}
+
+	// Show the current synced lyrics line if enabled
+	lyrics := startLyricsSession(input.Username, input.Track.ID, startTime, endTime, data)
+	if lyrics != nil {
+		data = lyrics.activityAt(now.UnixMilli())
+	}
+
+	// Send activity update
+	if err := rpc.sendActivity(clientID, input.Username, userToken, data); err != nil {
 		return fmt.Errorf("%w: failed to send activity: %v", scrobbler.ScrobblerErrorRetryLater, err)
 	}
+	awaitDiscordAvatar(clientID, input.Username, data)
 
-	// Schedule a timer to clear the activity after the track completes
-	remainingSeconds := int32(input.Track.Duration) - input.Position + 5
+	// Schedule a timer to clear the activity after the track completes, or after the idle
+	// timeout when its duration is unknown
+	remainingSeconds := clearDelay(input.Track.Duration, input.Position)
 	_, err = host.SchedulerScheduleOneTime(remainingSeconds, payloadClearActivity, fmt.Sprintf("%s-clear", input.Username))
 	if err != nil {
 		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to schedule completion timer: %v", err))
 	}
 
+	// Clear the activity early if a quiet hours window begins during the track
+	scheduleQuietHoursClear(input.Username, now, remainingSeconds)
+
+	// Schedule the next synced lyrics line
+	if lyrics != nil {
+		scheduleNextLyric(input.Username, lyrics, now.UnixMilli())
+	}
+
 	return nil
 }
 
@@ -239,10 +342,40 @@ func (p *discordPlugin) OnCallback(input scheduler.SchedulerCallbackRequest) err
 	case payloadClearActivity:
 		// Clear activity callback - scheduleId is "username-clear"
 		username := strings.TrimSuffix(input.ScheduleID, "-clear")
+		stopLyrics(username)
+		cancelImageRetry(username)
 		if err := rpc.handleClearActivityCallback(username); err != nil {
 			return err
 		}
 
+	case payloadQuietHours:
+		// Quiet hours callback - scheduleId is "username-quiet"
+		username := strings.TrimSuffix(input.ScheduleID, "-quiet")
+		_ = host.SchedulerCancelSchedule(fmt.Sprintf("%s-clear", username))
+		stopLyrics(username)
+		cancelImageRetry(username)
+		if err := rpc.handleClearActivityCallback(username); err != nil {
+			return err
+		}
+
+	case payloadLyrics:
+		// Lyrics callback - scheduleId is "username-lyrics"
+		username := strings.TrimSuffix(input.ScheduleID, "-lyrics")
+		if err := handleLyricsCallback(username); err != nil {
+			return err
+		}
+
+	case payloadImageRetry:
+		// Image retry callback - scheduleId is "username-images"
+		username := strings.TrimSuffix(input.ScheduleID, "-images")
+		if err := rpc.handleImageRetryCallback(username); err != nil {
+			return err
+		}
+
+	case payloadShareExpiry:
+		// Share expiry callback - scheduleId is the share's record key
+		handleShareExpiryCallback(input.ScheduleID)
+
 	default:
 		pdk.Log(pdk.LogWarn, fmt.Sprintf("Unknown scheduler callback payload: %s", input.Payload))
 	}
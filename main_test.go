@@ -35,6 +35,9 @@ var _ = Describe("discordPlugin", func() {
 		host.SubsonicAPIMock.Calls = nil
 		host.HTTPMock.ExpectedCalls = nil
 		host.HTTPMock.Calls = nil
+		host.SchedulerMock.On("CancelSchedule", "testuser-images").Return(nil).Maybe()
+		host.CacheMock.On("Remove", imageRetryKey("testuser")).Return(nil).Maybe()
+		host.CacheMock.On("GetString", rateLimitKey("test-client-id")).Return("", false, nil).Maybe()
 	})
 
 	Describe("getConfig", func() {
@@ -106,6 +109,33 @@ var _ = Describe("discordPlugin", func() {
 			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
 		})
 
+		// expectPresenceUpdate sets up the mocks for a NowPlaying call on an existing connection
+		// and returns a pointer to the last message sent to Discord.
+		expectPresenceUpdate := func() *string {
+			var sentPayload string
+			host.CacheMock.On("GetInt", "discord.seq.testuser").Return(int64(42), true, nil)
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+				sentPayload = args.Get(1).(string)
+			}).Return(nil)
+			host.SchedulerMock.On("CancelSchedule", "testuser-clear").Return(nil)
+			host.CacheMock.On("GetString", discordImageKey).Return("mp:cached/art", true, nil)
+			host.ArtworkMock.On("GetTrackUrl", "track1", int32(300)).Return("https://example.com/art.jpg", nil)
+			host.SchedulerMock.On("ScheduleOneTime", mock.Anything, payloadClearActivity, "testuser-clear").Return("testuser-clear", nil)
+			return &sentPayload
+		}
+
+		nowPlayingRequest := scrobbler.NowPlayingRequest{
+			Username: "testuser",
+			Position: 10,
+			Track: scrobbler.TrackInfo{
+				ID:       "track1",
+				Title:    "Test Song",
+				Artist:   "Test Artist",
+				Album:    "Test Album",
+				Duration: 180,
+			},
+		}
+
 		It("returns not authorized error when user not in config", func() {
 			pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
 			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"otheruser","token":"token"}]`, true)
@@ -124,6 +154,10 @@ var _ = Describe("discordPlugin", func() {
 			pdk.PDKMock.On("GetConfig", uguuEnabledKey).Return("", false)
 			pdk.PDKMock.On("GetConfig", activityNameKey).Return("", false)
 			pdk.PDKMock.On("GetConfig", spotifyLinksKey).Return("", false)
+			pdk.PDKMock.On("GetConfig", quietHoursKey).Return("", false)
+			pdk.PDKMock.On("GetConfig", artistFormatKey).Return("", false)
+			// Remaining optional settings use their defaults
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
 
 			// Connect mocks (isConnected check via heartbeat)
 			host.CacheMock.On("GetInt", "discord.seq.testuser").Return(int64(0), false, errors.New("not found"))
@@ -169,6 +203,145 @@ var _ = Describe("discordPlugin", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("skips connecting and sending and stops lyrics during quiet hours", func() {
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
+			pdk.PDKMock.On("GetConfig", quietHoursKey).Return(`[{"username":"testuser","start":"00:00","end":"00:00"}]`, true)
+			host.SchedulerMock.On("CancelSchedule", "testuser-lyrics").Return(nil)
+			host.CacheMock.On("Remove", "lyrics.session.testuser").Return(nil)
+
+			err := plugin.NowPlaying(scrobbler.NowPlayingRequest{
+				Username: "testuser",
+				Track:    scrobbler.TrackInfo{ID: "track1", Title: "Test Song", Duration: 180},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			host.WebSocketMock.AssertNotCalled(GinkgoT(), "Connect", mock.Anything, mock.Anything, mock.Anything)
+			host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+			host.SchedulerMock.AssertCalled(GinkgoT(), "CancelSchedule", "testuser-lyrics")
+		})
+
+		It("renders the rating indicator in the small text and templates", func() {
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
+			pdk.PDKMock.On("GetConfig", ratingDisplayKey).Return(ratingDisplaySmallText, true)
+			pdk.PDKMock.On("GetConfig", stateTemplateKey).Return("{artist} {rating}", true)
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			sentPayload := expectPresenceUpdate()
+
+			host.CacheMock.On("GetString", "subsonic.song.testuser.track1").Return("", false, nil)
+			host.CacheMock.On("SetString", "subsonic.song.testuser.track1", mock.Anything, songCacheTTL).Return(nil)
+			host.SubsonicAPIMock.On("Call", "/getSong?u=testuser&id=track1").
+				Return(`{"subsonic-response":{"status":"ok","song":{"id":"track1","starred":"2024-01-01T00:00:00Z","userRating":4}}}`, nil)
+
+			err := plugin.NowPlaying(nowPlayingRequest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*sentPayload).To(ContainSubstring(`"small_text":"♥ ★★★★"`))
+			Expect(*sentPayload).To(ContainSubstring(`"state":"Test Artist ♥ ★★★★"`))
+		})
+
+		It("adds the play count badge to the state", func() {
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
+			pdk.PDKMock.On("GetConfig", playCountBadgeKey).Return("true", true)
+			pdk.PDKMock.On("GetConfig", largeTextTemplateKey).Return("{album} · {playcount} plays", true)
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			sentPayload := expectPresenceUpdate()
+
+			host.CacheMock.On("GetInt", "playcount.testuser.track1").Return(int64(0), false, nil)
+			host.CacheMock.On("SetInt", "playcount.testuser.track1", int64(12), mock.Anything).Return(nil)
+			host.CacheMock.On("GetString", "subsonic.song.testuser.track1").Return("", false, nil)
+			host.CacheMock.On("SetString", "subsonic.song.testuser.track1", mock.Anything, songCacheTTL).Return(nil)
+			host.SubsonicAPIMock.On("Call", "/getSong?u=testuser&id=track1").
+				Return(`{"subsonic-response":{"status":"ok","song":{"id":"track1","playCount":12}}}`, nil)
+
+			err := plugin.NowPlaying(nowPlayingRequest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*sentPayload).To(ContainSubstring(`"state":"Test Artist · On repeat (12×)"`))
+			Expect(*sentPayload).To(ContainSubstring(`"large_text":"Test Album · 12 plays"`))
+			host.SubsonicAPIMock.AssertNumberOfCalls(GinkgoT(), "Call", 1)
+		})
+
+		It("omits timestamps when the timestamp mode is None", func() {
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
+			pdk.PDKMock.On("GetConfig", timestampModeKey).Return("None", true)
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			sentPayload := expectPresenceUpdate()
+
+			err := plugin.NowPlaying(nowPlayingRequest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*sentPayload).ToNot(ContainSubstring(`"timestamps"`))
+			host.SchedulerMock.AssertCalled(GinkgoT(), "ScheduleOneTime", int32(175), payloadClearActivity, "testuser-clear")
+		})
+
+		DescribeTable("clears streams without a duration after the idle timeout",
+			func(mode string) {
+				pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
+				pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
+				pdk.PDKMock.On("GetConfig", timestampModeKey).Return(mode, true)
+				pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+				sentPayload := expectPresenceUpdate()
+
+				stream := nowPlayingRequest
+				stream.Track.Duration = 0
+				err := plugin.NowPlaying(stream)
+				Expect(err).ToNot(HaveOccurred())
+				if mode == timestampModeNone {
+					Expect(*sentPayload).ToNot(ContainSubstring(`"timestamps"`))
+				} else {
+					Expect(*sentPayload).To(MatchRegexp(`"timestamps":\{"start":\d+\}`))
+				}
+				host.SchedulerMock.AssertCalled(GinkgoT(), "ScheduleOneTime", int32(defaultIdleTimeout*60), payloadClearActivity, "testuser-clear")
+			},
+			Entry("Full", timestampModeFull),
+			Entry("Elapsed", timestampModeElapsed),
+			Entry("Remaining", timestampModeRemaining),
+			Entry("None", timestampModeNone),
+		)
+
+		It("links to the user's preferred music service", func() {
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
+			pdk.PDKMock.On("GetConfig", linkServicesKey).Return(`[{"username":"testuser","services":["LastFM"]}]`, true)
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			sentPayload := expectPresenceUpdate()
+
+			lastFMKey := mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "lastfm.url.") })
+			host.CacheMock.On("GetString", lastFMKey).Return("", false, nil)
+			host.CacheMock.On("SetString", lastFMKey, mock.Anything, linkCacheTTLHit).Return(nil)
+
+			err := plugin.NowPlaying(nowPlayingRequest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*sentPayload).To(ContainSubstring(`"details_url":"https://www.last.fm/music/Test+Artist/_/Test+Song"`))
+			Expect(*sentPayload).To(ContainSubstring(`"state_url":"https://www.last.fm/music/Test+Artist"`))
+		})
+
+		It("shows the next track as the album art hover text", func() {
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
+			pdk.PDKMock.On("GetConfig", upNextKey).Return("true", true)
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			sentPayload := expectPresenceUpdate()
+
+			host.CacheMock.On("GetString", "subsonic.next.testuser.track1").Return(`{"id":"track2","title":"Next Song","artist":"Next Artist"}`, true, nil)
+
+			err := plugin.NowPlaying(nowPlayingRequest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*sentPayload).To(ContainSubstring(`"large_text":"Next: Next Song — Next Artist"`))
+		})
+
+		It("skips the rating lookup when it is not shown", func() {
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			sentPayload := expectPresenceUpdate()
+
+			err := plugin.NowPlaying(nowPlayingRequest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*sentPayload).To(ContainSubstring(`"small_text":"Navidrome"`))
+			host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "Call", mock.Anything)
+		})
+
 		DescribeTable("activity name configuration",
 			func(configValue string, configExists bool, expectedName string, expectedDisplayType int) {
 				pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
@@ -176,6 +349,10 @@ var _ = Describe("discordPlugin", func() {
 				pdk.PDKMock.On("GetConfig", uguuEnabledKey).Return("", false)
 				pdk.PDKMock.On("GetConfig", activityNameKey).Return(configValue, configExists)
 				pdk.PDKMock.On("GetConfig", spotifyLinksKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", quietHoursKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", artistFormatKey).Return("", false)
+				// Remaining optional settings use their defaults
+				pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
 
 				// Connect mocks
 				host.CacheMock.On("GetInt", "discord.seq.testuser").Return(int64(0), false, errors.New("not found"))
@@ -250,6 +427,8 @@ var _ = Describe("discordPlugin", func() {
 		})
 
 		It("handles clearActivity callback", func() {
+			host.SchedulerMock.On("CancelSchedule", "testuser-lyrics").Return(nil)
+			host.CacheMock.On("Remove", "lyrics.session.testuser").Return(nil)
 			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
 			host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
 			host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Navidrome disconnect").Return(nil)
@@ -261,6 +440,22 @@ var _ = Describe("discordPlugin", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("handles quiet hours callback", func() {
+			host.SchedulerMock.On("CancelSchedule", "testuser-lyrics").Return(nil)
+			host.CacheMock.On("Remove", "lyrics.session.testuser").Return(nil)
+			host.SchedulerMock.On("CancelSchedule", "testuser-clear").Return(nil)
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+			host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+			host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Navidrome disconnect").Return(nil)
+
+			err := plugin.OnCallback(scheduler.SchedulerCallbackRequest{
+				ScheduleID: "testuser-quiet",
+				Payload:    payloadQuietHours,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			host.SchedulerMock.AssertCalled(GinkgoT(), "CancelSchedule", "testuser-clear")
+		})
+
 		It("logs warning for unknown payload", func() {
 			err := plugin.OnCallback(scheduler.SchedulerCallbackRequest{
 				ScheduleID: "testuser",
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// metadataQuery is one artist/title/album combination to look a track up with.
+type metadataQuery struct {
+	Artist string
+	Title  string
+	Album  string
+}
+
+var (
+	// punctuationReplacer maps typographic punctuation to its ASCII form.
+	punctuationReplacer = strings.NewReplacer(
+		"‘", "'", "’", "'", "‚", "'", "‛", "'", "´", "'", "`", "'",
+		"“", `"`, "”", `"`, "„", `"`, "‟", `"`,
+		"‐", "-", "‑", "-", "‒", "-", "–", "-", "—", "-", "―", "-", "−", "-",
+	)
+
+	// decorationGroupRe matches bracketed decorations, e.g. "(2011 Remaster)", "[feat. X]" or "(Live at Wembley)".
+	// "feat" and "ft" need their dot, so words such as "Feat of Clay" are kept.
+	decorationGroupRe = regexp.MustCompile(`(?i)\s*[(\[][^)\]]*(\b(remaster(ed)?|live|featuring|deluxe|expanded|anniversary)\b|\b(feat|ft)\.)[^)\]]*[)\]]`)
+
+	// decorationSuffixRe matches dash-separated decorations, e.g. " - Remastered 2011" or " - Live".
+	decorationSuffixRe = regexp.MustCompile(`(?i)\s+-\s+[^-]*\b(remaster(ed)?|live|deluxe|expanded|anniversary)\b[^-]*$`)
+
+	// featuringRe matches an unbracketed featured artist credit and everything after it. As
+	// in decorationGroupRe, "feat" and "ft" need their dot: "A Feat of Strength" is a title.
+	featuringRe = regexp.MustCompile(`(?i)\s+(feat\.|ft\.|featuring)\s+.*$`)
+
+	caseFolder = cases.Fold()
+)
+
+// cleanMetadata applies Unicode NFKC normalization, replaces typographic punctuation and
+// collapses whitespace. Letter case is kept.
+func cleanMetadata(s string) string {
+	s = punctuationReplacer.Replace(norm.NFKC.String(s))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// foldMetadata returns the case-folded clean form of s, for comparisons and cache keys.
+func foldMetadata(s string) string {
+	return caseFolder.String(cleanMetadata(s))
+}
+
+// stripDecorations removes remaster, live, edition and featured artist decorations from a title or album.
+func stripDecorations(s string) string {
+	s = cleanMetadata(s)
+	s = decorationGroupRe.ReplaceAllString(s, "")
+	s = decorationSuffixRe.ReplaceAllString(s, "")
+	s = featuringRe.ReplaceAllString(s, "")
+	return strings.TrimSpace(s)
+}
+
+// mainArtist returns the main artist of a credit such as "A feat. B, C & D".
+func mainArtist(artist string) string {
+	return strings.TrimSpace(featuringRe.ReplaceAllString(cleanMetadata(artist), ""))
+}
+
+// metadataVariants returns progressively simpler lookups for a track: the tags as they
+// are, then without decorations and featured artists, then without the album.
+// Duplicates and variants without an artist or title are left out.
+func metadataVariants(artist, title, album string) []metadataQuery {
+	simpleArtist := mainArtist(artist)
+	candidates := []metadataQuery{
+		{Artist: artist, Title: title, Album: album},
+		{Artist: simpleArtist, Title: stripDecorations(title), Album: stripDecorations(album)},
+		{Artist: simpleArtist, Title: stripDecorations(title)},
+	}
+
+	var variants []metadataQuery
+	seen := map[metadataQuery]bool{}
+	for _, c := range candidates {
+		if c.Artist == "" || c.Title == "" {
+			continue
+		}
+		key := metadataQuery{Artist: foldMetadata(c.Artist), Title: foldMetadata(c.Title), Album: foldMetadata(c.Album)}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		variants = append(variants, c)
+	}
+	return variants
+}
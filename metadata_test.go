@@ -0,0 +1,80 @@
+package main
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metadata normalization", func() {
+	DescribeTable("cleanMetadata",
+		func(input, expected string) {
+			Expect(cleanMetadata(input)).To(Equal(expected))
+		},
+		Entry("keeps plain text", "Karma Police", "Karma Police"),
+		Entry("normalizes compatibility characters", "ＡＢＣ ﬁne", "ABC fine"),
+		Entry("replaces typographic quotes", "Don’t Stop “Me”", `Don't Stop "Me"`),
+		Entry("replaces dashes", "Song – Live", "Song - Live"),
+		Entry("collapses whitespace", "  Karma   Police ", "Karma Police"),
+	)
+
+	Describe("foldMetadata", func() {
+		It("folds case", func() {
+			Expect(foldMetadata("KARMA Police")).To(Equal(foldMetadata("karma police")))
+		})
+
+		It("folds special cases beyond lowercasing", func() {
+			Expect(foldMetadata("STRASSE")).To(Equal(foldMetadata("straße")))
+		})
+	})
+
+	DescribeTable("stripDecorations",
+		func(input, expected string) {
+			Expect(stripDecorations(input)).To(Equal(expected))
+		},
+		Entry("bracketed remaster", "Song (2011 Remaster)", "Song"),
+		Entry("dash remastered", "Song - Remastered", "Song"),
+		Entry("dash remaster with year", "Song - Remastered 2011", "Song"),
+		Entry("bracketed featuring", "Song [feat. X]", "Song"),
+		Entry("unbracketed featuring", "Song ft. X & Y", "Song"),
+		Entry("live recording", "Song (Live at Wembley)", "Song"),
+		Entry("deluxe edition album", "Album (Deluxe Edition)", "Album"),
+		Entry("keeps other brackets", "Song (Part 2)", "Song (Part 2)"),
+		Entry("keeps words containing live", "Alive - Oliver", "Alive - Oliver"),
+		Entry("keeps feat without a dot", "A Feat of Strength", "A Feat of Strength"),
+		Entry("keeps ft without a dot", "Lift Ft Off", "Lift Ft Off"),
+		Entry("keeps bracketed feat without a dot", "Song (Feat of Clay)", "Song (Feat of Clay)"),
+	)
+
+	DescribeTable("mainArtist",
+		func(input, expected string) {
+			Expect(mainArtist(input)).To(Equal(expected))
+		},
+		Entry("drops featured artists", "A feat. B, C & D", "A"),
+		Entry("drops ft. credits", "A ft. B", "A"),
+		Entry("drops featuring credits", "A Featuring B", "A"),
+		Entry("keeps credits without featured artists", "Simon & Garfunkel", "Simon & Garfunkel"),
+		Entry("keeps feat without a dot", "Little Feat & Friends", "Little Feat & Friends"),
+		Entry("keeps ft without a dot", "Daft Ft Band", "Daft Ft Band"),
+	)
+
+	Describe("metadataVariants", func() {
+		It("simplifies progressively", func() {
+			Expect(metadataVariants("A ft. B", "Song (2011 Remaster)", "Album (Deluxe)")).To(Equal([]metadataQuery{
+				{Artist: "A ft. B", Title: "Song (2011 Remaster)", Album: "Album (Deluxe)"},
+				{Artist: "A", Title: "Song", Album: "Album"},
+				{Artist: "A", Title: "Song"},
+			}))
+		})
+
+		It("leaves out duplicate variants", func() {
+			Expect(metadataVariants("A", "Song", "Album")).To(Equal([]metadataQuery{
+				{Artist: "A", Title: "Song", Album: "Album"},
+				{Artist: "A", Title: "Song"},
+			}))
+		})
+
+		It("returns nothing without an artist or title", func() {
+			Expect(metadataVariants("", "Song", "Album")).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+)
+
+// Configuration keys for MusicBrainz lookups
+const (
+	mbidLookupKey     = "mbidlookup"
+	musicBrainzURLKey = "musicbrainzurl"
+)
+
+const (
+	defaultMusicBrainzURL = "https://musicbrainz.org"
+
+	// musicBrainzThrottleKey caches the time of the last request to musicbrainz.org, in
+	// Unix milliseconds.
+	musicBrainzThrottleKey = "musicbrainz.lastrequest"
+	musicBrainzInterval    = time.Second // musicbrainz.org allows one request per second
+)
+
+// musicBrainzUserAgent identifies the plugin and its version, as required by the MusicBrainz API.
+var musicBrainzUserAgent = "NavidromeDiscordRichPresence/" + pluginVersion + " ( https://github.com/navidrome/discord-rich-presence-plugin )"
+
+const (
+	recordingSearchLimit    = 10
+	recordingMinScore       = 80   // Minimum MusicBrainz search score of an accepted recording
+	recordingDurationMargin = 5000 // Accepted length difference, in milliseconds
+)
+
+// musicBrainzRelations captures the URL relationships of a MusicBrainz entity.
+type musicBrainzRelations struct {
+	Relations []struct {
+		URL struct {
+			Resource string `json:"resource"`
+		} `json:"url"`
+	} `json:"relations"`
+}
+
+// musicBrainzRecording is a recording in MusicBrainz search results.
+type musicBrainzRecording struct {
+	ID           string `json:"id"`
+	Score        int    `json:"score"`
+	Title        string `json:"title"`
+	Length       int64  `json:"length"` // Milliseconds, 0 when unknown
+	ArtistCredit []struct {
+		Name string `json:"name"`
+	} `json:"artist-credit"`
+	Releases []struct {
+		Title string `json:"title"`
+	} `json:"releases"`
+}
+
+// musicBrainzRecordingSearch captures a MusicBrainz recording search response.
+type musicBrainzRecordingSearch struct {
+	Recordings []musicBrainzRecording `json:"recordings"`
+}
+
+// getMusicBrainzAPIBase returns the web service root of the configured MusicBrainz server,
+// which may be a local mirror.
+func getMusicBrainzAPIBase() string {
+	base, _ := pdk.GetConfig(musicBrainzURLKey)
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	if base == "" {
+		base = defaultMusicBrainzURL
+	}
+	return base + "/ws/2"
+}
+
+// fetchMusicBrainz calls the MusicBrainz web service and decodes the JSON response into v.
+func fetchMusicBrainz(path string, v any) error {
+	base := getMusicBrainzAPIBase()
+	if base == defaultMusicBrainzURL+"/ws/2" && !allowMusicBrainzRequest(time.Now()) {
+		return errServiceUnavailable
+	}
+	return fetchJSON(base+path, map[string]string{
+		"Accept":     "application/json",
+		"User-Agent": musicBrainzUserAgent,
+	}, v)
+}
+
+// allowMusicBrainzRequest keeps requests to musicbrainz.org at most one per interval, as its
+// rate limit requires, and records the time of an allowed request. A single now-playing
+// update can make several requests in a row; the later ones are skipped rather than delayed,
+// so their callers cache them as unavailable and retry on a later play. Mirrors aren't limited.
+func allowMusicBrainzRequest(now time.Time) bool {
+	if cached, exists, err := host.CacheGetString(musicBrainzThrottleKey); err == nil && exists {
+		if last, err := strconv.ParseInt(cached, 10, 64); err == nil && now.Sub(time.UnixMilli(last)) < musicBrainzInterval {
+			pdk.Log(pdk.LogDebug, "Skipping MusicBrainz request, the previous one was less than a second ago")
+			return false
+		}
+	}
+	_ = host.CacheSetString(musicBrainzThrottleKey, strconv.FormatInt(now.UnixMilli(), 10), 60)
+	return true
+}
+
+// musicBrainzURLRels returns the URLs linked to a MusicBrainz entity, such as an artist or release.
+func musicBrainzURLRels(entity, mbid string) ([]string, error) {
+	if !isValidMBID(mbid) {
+		return nil, fmt.Errorf("invalid MBID %q", mbid)
+	}
+	var resp musicBrainzRelations
+	if err := fetchMusicBrainz(fmt.Sprintf("/%s/%s?inc=url-rels&fmt=json", entity, url.PathEscape(mbid)), &resp); err != nil {
+		return nil, fmt.Errorf("failed to fetch %s url-rels: %w", entity, err)
+	}
+	urls := make([]string, 0, len(resp.Relations))
+	for _, r := range resp.Relations {
+		if r.URL.Resource != "" {
+			urls = append(urls, r.URL.Resource)
+		}
+	}
+	return urls, nil
+}
+
+// lookupRecordingMBID returns the recording MBID of an untagged track, found with a MusicBrainz
+// recording search when MBID lookups are enabled. Results, including misses, are cached;
+// failed searches only briefly.
+func lookupRecordingMBID(track scrobbler.TrackInfo) string {
+	if enabled, _ := pdk.GetConfig(mbidLookupKey); enabled != "true" {
+		return ""
+	}
+	artist := primaryArtist(track)
+	if artist == "" || track.Title == "" {
+		return ""
+	}
+	cacheKey := "musicbrainz.recording." + hashKey(foldMetadata(artist)+"\x00"+foldMetadata(track.Title)+"\x00"+foldMetadata(track.Album))
+	return cachedLookup(cacheKey, func() (string, error) {
+		mbid, err := searchRecordingMBID(artist, track.Title, track.Album, int64(track.Duration*1000))
+		if err != nil {
+			pdk.Log(pdk.LogInfo, fmt.Sprintf("MusicBrainz recording search failed for %q - %q: %v", artist, track.Title, err))
+			return "", err
+		}
+		if mbid == "" {
+			pdk.Log(pdk.LogDebug, fmt.Sprintf("No confident MusicBrainz match for %q - %q", artist, track.Title))
+		} else {
+			pdk.Log(pdk.LogInfo, fmt.Sprintf("Matched %q - %q to MusicBrainz recording %s", artist, track.Title, mbid))
+		}
+		return mbid, nil
+	})
+}
+
+// searchRecordingMBID searches MusicBrainz for a recording and returns the MBID of the best
+// confident match, or "" when there is none. length is the track length in milliseconds.
+func searchRecordingMBID(artist, title, album string, length int64) (string, error) {
+	query := fmt.Sprintf(`recording:"%s" AND artist:"%s"`, luceneEscape(stripDecorations(title)), luceneEscape(artist))
+	if album != "" {
+		// Optional clause: it ranks recordings from the album higher without excluding others
+		query += fmt.Sprintf(` release:"%s"`, luceneEscape(stripDecorations(album)))
+	}
+
+	var resp musicBrainzRecordingSearch
+	path := fmt.Sprintf("/recording?fmt=json&limit=%d&query=%s", recordingSearchLimit, url.QueryEscape(query))
+	if err := fetchMusicBrainz(path, &resp); err != nil {
+		return "", err
+	}
+
+	var best *musicBrainzRecording
+	bestRank := -1
+	for i := range resp.Recordings {
+		rank, ok := scoreRecording(resp.Recordings[i], artist, title, album, length)
+		if ok && rank > bestRank {
+			best, bestRank = &resp.Recordings[i], rank
+		}
+	}
+	if best == nil {
+		return "", nil
+	}
+	return best.ID, nil
+}
+
+// scoreRecording checks that a search result is a confident match for the track and ranks it.
+// A match needs a high search score, the same title and artist, and a length within the margin
+// when both are known. Matches on the album are ranked higher.
+func scoreRecording(r musicBrainzRecording, artist, title, album string, length int64) (int, bool) {
+	if !isValidMBID(r.ID) || r.Score < recordingMinScore {
+		return 0, false
+	}
+	if foldMetadata(stripDecorations(r.Title)) != foldMetadata(stripDecorations(title)) {
+		return 0, false
+	}
+
+	credited := false
+	for _, c := range r.ArtistCredit {
+		if foldMetadata(c.Name) == foldMetadata(artist) {
+			credited = true
+			break
+		}
+	}
+	if !credited {
+		return 0, false
+	}
+
+	if length > 0 && r.Length > 0 && abs(r.Length-length) > recordingDurationMargin {
+		return 0, false
+	}
+
+	rank := r.Score
+	if album != "" {
+		for _, rel := range r.Releases {
+			if foldMetadata(stripDecorations(rel.Title)) == foldMetadata(stripDecorations(album)) {
+				rank += 100
+				break
+			}
+		}
+	}
+	return rank, true
+}
+
+// luceneEscape escapes s for use inside a quoted Lucene search term.
+func luceneEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
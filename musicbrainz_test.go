@@ -0,0 +1,239 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MusicBrainz", func() {
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.HTTPMock.ExpectedCalls = nil
+		host.HTTPMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		host.CacheMock.On("GetString", musicBrainzThrottleKey).Return("", false, nil).Maybe()
+		host.CacheMock.On("SetString", musicBrainzThrottleKey, mock.Anything, mock.Anything).Return(nil).Maybe()
+	})
+
+	Describe("getMusicBrainzAPIBase", func() {
+		It("defaults to musicbrainz.org", func() {
+			pdk.PDKMock.On("GetConfig", musicBrainzURLKey).Return("", false)
+			Expect(getMusicBrainzAPIBase()).To(Equal("https://musicbrainz.org/ws/2"))
+		})
+
+		It("uses a configured mirror", func() {
+			pdk.PDKMock.On("GetConfig", musicBrainzURLKey).Return(" http://mb.local:5000/ ", true)
+			Expect(getMusicBrainzAPIBase()).To(Equal("http://mb.local:5000/ws/2"))
+		})
+	})
+
+	Describe("musicBrainzURLRels", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("GetConfig", musicBrainzURLKey).Return("", false)
+		})
+
+		It("returns the entity's URLs and identifies the plugin", func() {
+			var sent host.HTTPRequest
+			host.HTTPMock.On("Send", mock.Anything).Run(func(args mock.Arguments) {
+				sent = args.Get(0).(host.HTTPRequest)
+			}).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"relations":[
+				{"type":"official homepage","url":{"resource":"https://www.radiohead.com/"}},
+				{"type":"free streaming","url":{"resource":"https://open.spotify.com/artist/4Z8W4fKeB5YxbusRsdQVPb"}},
+				{"type":"member of band","artist":{"name":"Thom Yorke"}}]}`)}, nil)
+
+			urls, err := musicBrainzURLRels("artist", "a74b1b7f-71a5-4011-9441-d0b5e4122711")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(urls).To(Equal([]string{"https://www.radiohead.com/", "https://open.spotify.com/artist/4Z8W4fKeB5YxbusRsdQVPb"}))
+			Expect(sent.URL).To(Equal("https://musicbrainz.org/ws/2/artist/a74b1b7f-71a5-4011-9441-d0b5e4122711?inc=url-rels&fmt=json"))
+			Expect(sent.Headers).To(HaveKeyWithValue("User-Agent", musicBrainzUserAgent))
+			Expect(musicBrainzUserAgent).To(HavePrefix("NavidromeDiscordRichPresence/" + pluginVersion + " ("))
+			Expect(pluginVersion).To(MatchRegexp(`^\d+\.\d+\.\d+`))
+		})
+
+		It("rejects invalid MBIDs without a request", func() {
+			_, err := musicBrainzURLRels("artist", "not-an-mbid")
+			Expect(err).To(HaveOccurred())
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+
+		It("returns an error on HTTP failures", func() {
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 503}, nil)
+
+			_, err := musicBrainzURLRels("release", "52709206-8816-3c12-9ff6-f957f2f1eecf")
+			Expect(err).To(MatchError(ContainSubstring("HTTP 503")))
+		})
+	})
+
+	Describe("allowMusicBrainzRequest", func() {
+		BeforeEach(func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("SetString", musicBrainzThrottleKey, mock.Anything, int64(60)).Return(nil)
+		})
+
+		It("skips requests less than a second after the last one", func() {
+			host.CacheMock.On("GetString", musicBrainzThrottleKey).Return("1699999999700", true, nil)
+
+			Expect(allowMusicBrainzRequest(time.UnixMilli(1700000000000))).To(BeFalse())
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("allows and records requests after a second", func() {
+			host.CacheMock.On("GetString", musicBrainzThrottleKey).Return("1699999998000", true, nil)
+
+			Expect(allowMusicBrainzRequest(time.UnixMilli(1700000000000))).To(BeTrue())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", musicBrainzThrottleKey, "1700000000000", int64(60))
+		})
+
+		It("returns errServiceUnavailable without a request while throttled", func() {
+			pdk.PDKMock.On("GetConfig", musicBrainzURLKey).Return("", false)
+			host.CacheMock.On("GetString", musicBrainzThrottleKey).Return(strconv.FormatInt(time.Now().UnixMilli(), 10), true, nil)
+
+			_, err := musicBrainzURLRels("artist", "a74b1b7f-71a5-4011-9441-d0b5e4122711")
+			Expect(err).To(MatchError(errServiceUnavailable))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+
+		It("doesn't throttle mirrors", func() {
+			pdk.PDKMock.On("GetConfig", musicBrainzURLKey).Return("http://mb.local", true)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"relations":[]}`)}, nil)
+
+			_, err := musicBrainzURLRels("artist", "a74b1b7f-71a5-4011-9441-d0b5e4122711")
+			Expect(err).ToNot(HaveOccurred())
+			host.CacheMock.AssertNotCalled(GinkgoT(), "GetString", musicBrainzThrottleKey)
+		})
+	})
+
+	Describe("scoreRecording", func() {
+		recording := func() musicBrainzRecording {
+			r := musicBrainzRecording{
+				ID:     "b1a9c0e9-d987-4042-ae91-78d6a3267d69",
+				Score:  100,
+				Title:  "Karma Police",
+				Length: 264066,
+			}
+			r.ArtistCredit = append(r.ArtistCredit, struct {
+				Name string `json:"name"`
+			}{Name: "Radiohead"})
+			r.Releases = append(r.Releases, struct {
+				Title string `json:"title"`
+			}{Title: "OK Computer"})
+			return r
+		}
+
+		It("accepts a matching recording and ranks album matches higher", func() {
+			withAlbum, ok := scoreRecording(recording(), "Radiohead", "Karma Police", "OK Computer", 264000)
+			Expect(ok).To(BeTrue())
+			withoutAlbum, ok := scoreRecording(recording(), "Radiohead", "Karma Police", "Other Album", 264000)
+			Expect(ok).To(BeTrue())
+			Expect(withAlbum).To(BeNumerically(">", withoutAlbum))
+		})
+
+		It("ignores decorations and case in titles", func() {
+			_, ok := scoreRecording(recording(), "radiohead", "Karma Police (2011 Remaster)", "", 0)
+			Expect(ok).To(BeTrue())
+		})
+
+		DescribeTable("rejects uncertain matches",
+			func(change func(*musicBrainzRecording), artist, title string, length int64) {
+				r := recording()
+				change(&r)
+				_, ok := scoreRecording(r, artist, title, "OK Computer", length)
+				Expect(ok).To(BeFalse())
+			},
+			Entry("low search score", func(r *musicBrainzRecording) { r.Score = 60 }, "Radiohead", "Karma Police", int64(264000)),
+			Entry("different title", func(r *musicBrainzRecording) {}, "Radiohead", "Airbag", int64(264000)),
+			Entry("different artist", func(r *musicBrainzRecording) {}, "Coldplay", "Karma Police", int64(264000)),
+			Entry("length out of tolerance", func(r *musicBrainzRecording) {}, "Radiohead", "Karma Police", int64(300000)),
+			Entry("invalid MBID", func(r *musicBrainzRecording) { r.ID = "x" }, "Radiohead", "Karma Police", int64(264000)),
+		)
+	})
+
+	Describe("lookupRecordingMBID", func() {
+		track := scrobbler.TrackInfo{
+			Title:    "Karma Police",
+			Album:    "OK Computer",
+			Artists:  []scrobbler.ArtistRef{{Name: "Radiohead"}},
+			Duration: 264,
+		}
+		recordingKey := mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "musicbrainz.recording.") })
+
+		It("does nothing when disabled", func() {
+			pdk.PDKMock.On("GetConfig", mbidLookupKey).Return("", false)
+
+			Expect(lookupRecordingMBID(track)).To(BeEmpty())
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+
+		It("searches for the recording and caches the best match", func() {
+			pdk.PDKMock.On("GetConfig", mbidLookupKey).Return("true", true)
+			pdk.PDKMock.On("GetConfig", musicBrainzURLKey).Return("http://mb.local", true)
+			host.CacheMock.On("GetString", recordingKey).Return("", false, nil)
+			host.CacheMock.On("SetString", recordingKey, mock.Anything, mock.Anything).Return(nil)
+
+			var searchURL string
+			host.HTTPMock.On("Send", mock.Anything).Run(func(args mock.Arguments) {
+				searchURL = args.Get(0).(host.HTTPRequest).URL
+			}).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"recordings":[
+				{"id":"11111111-1111-1111-1111-111111111111","score":100,"title":"Karma Police","length":420000,"artist-credit":[{"name":"Radiohead"}]},
+				{"id":"22222222-2222-2222-2222-222222222222","score":95,"title":"Karma Police","length":263000,"artist-credit":[{"name":"Radiohead"}],"releases":[{"title":"Greatest Hits"}]},
+				{"id":"33333333-3333-3333-3333-333333333333","score":90,"title":"Karma Police","length":264066,"artist-credit":[{"name":"Radiohead"}],"releases":[{"title":"OK Computer"}]}]}`)}, nil)
+
+			Expect(lookupRecordingMBID(track)).To(Equal("33333333-3333-3333-3333-333333333333"))
+			Expect(searchURL).To(HavePrefix("http://mb.local/ws/2/recording?fmt=json&limit=10&query="))
+			Expect(searchURL).To(ContainSubstring("recording%3A%22Karma+Police%22"))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", recordingKey, "33333333-3333-3333-3333-333333333333", linkCacheTTLHit)
+		})
+
+		It("caches a miss when no match is confident", func() {
+			pdk.PDKMock.On("GetConfig", mbidLookupKey).Return("true", true)
+			pdk.PDKMock.On("GetConfig", musicBrainzURLKey).Return("", false)
+			host.CacheMock.On("GetString", recordingKey).Return("", false, nil)
+			host.CacheMock.On("SetString", recordingKey, "", linkCacheTTLMiss).Return(nil)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"recordings":[
+				{"id":"11111111-1111-1111-1111-111111111111","score":100,"title":"Karma Police (Cover)","artist-credit":[{"name":"Someone"}]}]}`)}, nil)
+
+			Expect(lookupRecordingMBID(track)).To(BeEmpty())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", recordingKey, "", linkCacheTTLMiss)
+		})
+
+		It("caches a failed search only briefly", func() {
+			pdk.PDKMock.On("GetConfig", mbidLookupKey).Return("true", true)
+			pdk.PDKMock.On("GetConfig", musicBrainzURLKey).Return("", false)
+			host.CacheMock.On("GetString", recordingKey).Return("", false, nil)
+			host.CacheMock.On("SetString", recordingKey, "", linkCacheTTLUnavailable).Return(nil)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 503}, nil)
+
+			Expect(lookupRecordingMBID(track)).To(BeEmpty())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", recordingKey, "", linkCacheTTLUnavailable)
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", recordingKey, "", linkCacheTTLMiss)
+		})
+
+		It("uses the cached MBID", func() {
+			pdk.PDKMock.On("GetConfig", mbidLookupKey).Return("true", true)
+			host.CacheMock.On("GetString", recordingKey).Return("33333333-3333-3333-3333-333333333333", true, nil)
+
+			Expect(lookupRecordingMBID(track)).To(Equal("33333333-3333-3333-3333-333333333333"))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+	})
+
+	DescribeTable("luceneEscape",
+		func(input, expected string) {
+			Expect(luceneEscape(input)).To(Equal(expected))
+		},
+		Entry("plain", "Karma Police", "Karma Police"),
+		Entry("quotes", `Say "Hi"`, `Say \"Hi\"`),
+		Entry("backslashes", `AC\DC`, `AC\\DC`),
+	)
+})
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// Configuration keys for play count badges
+const (
+	playCountBadgeKey  = "playcountbadge"
+	repeatThresholdKey = "repeatthreshold"
+)
+
+// defaultRepeatThreshold is the play count from which a track is shown as "On repeat".
+const defaultRepeatThreshold int64 = 10
+
+// playCountBadge returns a badge for notable play counts: "First listen!" for a track that
+// has never been played, and "On repeat (12×)" once the threshold is reached.
+// The count is the one read when the current play started, see getPlayCountInfo.
+func playCountBadge(playCount, repeatThreshold int64) string {
+	switch {
+	case playCount <= 0:
+		return "First listen!"
+	case repeatThreshold > 0 && playCount >= repeatThreshold:
+		return fmt.Sprintf("On repeat (%d×)", playCount)
+	}
+	return ""
+}
+
+// getRepeatThreshold loads the configured repeat threshold, 0 to disable the badge.
+func getRepeatThreshold() int64 {
+	return int64(configInt(repeatThresholdKey, int(defaultRepeatThreshold), 0, math.MaxInt32))
+}
+
+// playCountKey returns the cache key for the play count read when the user's track started.
+func playCountKey(username, trackID string) string {
+	return fmt.Sprintf("playcount.%s.%s", username, trackID)
+}
+
+// getPlayCountInfo looks up the play count and badge for the user's current track, if they
+// are shown anywhere. The third return value reports whether the badge should be added to the state.
+// The count is cached for the remaining seconds of the track, so updates after a pause or seek
+// keep the count from before the current play was scrobbled, and a replay reads it again.
+func getPlayCountInfo(username, trackID string, remaining int64) (playCount, badge string, showBadge bool) {
+	enabled, _ := pdk.GetConfig(playCountBadgeKey)
+	showBadge = enabled == "true"
+	if !showBadge && !templatesUse(placeholderPlayCount) && !templatesUse(placeholderPlayBadge) {
+		return "", "", false
+	}
+
+	cacheKey := playCountKey(username, trackID)
+	count, exists, err := host.CacheGetInt(cacheKey)
+	if err != nil || !exists {
+		song, err := getSong(username, trackID)
+		if err != nil {
+			pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to get play count for track %s: %v", trackID, err))
+			return "", "", showBadge
+		}
+		count = song.PlayCount
+		if remaining > 0 {
+			_ = host.CacheSetInt(cacheKey, count, remaining)
+		}
+	}
+	return strconv.FormatInt(count, 10), playCountBadge(count, getRepeatThreshold()), showBadge
+}
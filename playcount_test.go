@@ -0,0 +1,95 @@
+package main
+
+import (
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Play count", func() {
+	Describe("playCountBadge", func() {
+		DescribeTable("renders badges for notable play counts",
+			func(playCount, threshold int64, expected string) {
+				Expect(playCountBadge(playCount, threshold)).To(Equal(expected))
+			},
+			Entry("never played", int64(0), int64(10), "First listen!"),
+			Entry("played a few times", int64(3), int64(10), ""),
+			Entry("at the threshold", int64(10), int64(10), "On repeat (10×)"),
+			Entry("above the threshold", int64(12), int64(10), "On repeat (12×)"),
+			Entry("threshold disabled", int64(50), int64(0), ""),
+		)
+	})
+
+	Describe("getPlayCountInfo", func() {
+		BeforeEach(func() {
+			pdk.ResetMock()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.Calls = nil
+			host.SubsonicAPIMock.ExpectedCalls = nil
+			host.SubsonicAPIMock.Calls = nil
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.CacheMock.On("SetInt", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+		})
+
+		It("does not look up the song when nothing shows the play count", func() {
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+
+			count, badge, show := getPlayCountInfo("testuser", "track1", 180)
+			Expect(count).To(BeEmpty())
+			Expect(badge).To(BeEmpty())
+			Expect(show).To(BeFalse())
+			host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "Call", mock.Anything)
+		})
+
+		It("uses the configured repeat threshold", func() {
+			pdk.PDKMock.On("GetConfig", playCountBadgeKey).Return("true", true)
+			pdk.PDKMock.On("GetConfig", repeatThresholdKey).Return("5", true)
+			host.CacheMock.On("GetInt", "playcount.testuser.track1").Return(int64(0), false, nil)
+			host.CacheMock.On("GetString", "subsonic.song.testuser.track1").Return(`{"id":"track1","playCount":7}`, true, nil)
+
+			count, badge, show := getPlayCountInfo("testuser", "track1", 180)
+			Expect(count).To(Equal("7"))
+			Expect(badge).To(Equal("On repeat (7×)"))
+			Expect(show).To(BeTrue())
+		})
+
+		It("falls back to the default threshold for invalid config", func() {
+			pdk.PDKMock.On("GetConfig", playCountBadgeKey).Return("", false)
+			pdk.PDKMock.On("GetConfig", largeTextTemplateKey).Return("{album} ({playcount} plays)", true)
+			pdk.PDKMock.On("GetConfig", repeatThresholdKey).Return("often", true)
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			host.CacheMock.On("GetInt", "playcount.testuser.track1").Return(int64(0), false, nil)
+			host.CacheMock.On("GetString", "subsonic.song.testuser.track1").Return(`{"id":"track1","playCount":7}`, true, nil)
+
+			count, badge, show := getPlayCountInfo("testuser", "track1", 180)
+			Expect(count).To(Equal("7"))
+			Expect(badge).To(BeEmpty())
+			Expect(show).To(BeFalse())
+		})
+
+		It("caches the count for the rest of the track", func() {
+			pdk.PDKMock.On("GetConfig", playCountBadgeKey).Return("true", true)
+			pdk.PDKMock.On("GetConfig", repeatThresholdKey).Return("", false)
+			host.CacheMock.On("GetInt", "playcount.testuser.track1").Return(int64(0), false, nil)
+			host.CacheMock.On("GetString", "subsonic.song.testuser.track1").Return(`{"id":"track1","playCount":0}`, true, nil)
+
+			_, badge, _ := getPlayCountInfo("testuser", "track1", 120)
+			Expect(badge).To(Equal("First listen!"))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetInt", "playcount.testuser.track1", int64(0), int64(120))
+		})
+
+		It("keeps the count from the start of the play", func() {
+			pdk.PDKMock.On("GetConfig", playCountBadgeKey).Return("true", true)
+			pdk.PDKMock.On("GetConfig", repeatThresholdKey).Return("", false)
+			host.CacheMock.On("GetInt", "playcount.testuser.track1").Return(int64(0), true, nil)
+
+			count, badge, _ := getPlayCountInfo("testuser", "track1", 60)
+			Expect(count).To(Equal("0"))
+			Expect(badge).To(Equal("First listen!"))
+			host.CacheMock.AssertNotCalled(GinkgoT(), "GetString", "subsonic.song.testuser.track1")
+		})
+	})
+})
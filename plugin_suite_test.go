@@ -20,4 +20,16 @@ var (
 	discordImageKey   = mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "discord.image.") })
 	externalAssetsReq = mock.MatchedBy(func(req host.HTTPRequest) bool { return strings.Contains(req.URL, "external-assets") })
 	spotifyURLKey     = mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "spotify.url.") })
+	artworkExpiryArg  = mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "artwork.expiry.") })
 )
+
+// Every spec starts with an empty resolution store that accepts writes. Specs that exercise
+// the store reset these expectations and register their own.
+var _ = BeforeEach(func() {
+	host.KVStoreMock.ExpectedCalls = nil
+	host.KVStoreMock.Calls = nil
+	host.KVStoreMock.On("Get", mock.Anything).Return([]byte(nil), false, nil).Maybe()
+	host.KVStoreMock.On("Set", mock.Anything, mock.Anything).Return(nil).Maybe()
+	host.KVStoreMock.On("GetStorageUsed").Return(int64(0), nil).Maybe()
+	host.KVStoreMock.On("Delete", mock.Anything).Return(nil).Maybe()
+})
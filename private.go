@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// privateHostSuffixes are name suffixes that only resolve on local networks: mDNS (.local),
+// the home network domain (RFC 8375) and common router and container defaults.
+var privateHostSuffixes = []string{".local", ".localhost", ".home.arpa", ".lan", ".internal", ".localdomain"}
+
+// cgnatPrefix is the shared address space (RFC 6598) used by carrier-grade NAT and by
+// overlay networks such as Tailscale.
+var cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPrivateURL reports whether Discord can't fetch rawURL because its host is only reachable
+// on a local network. Unparsable URLs count as private, as Discord can't fetch them either.
+func isPrivateURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return true
+	}
+	return isPrivateHost(u.Hostname())
+}
+
+// isPrivateHost reports whether host is a loopback, private (RFC 1918, IPv6 ULA), link-local,
+// unspecified or shared address, or a name that only resolves locally. Single-label names,
+// such as Docker service names, count as local too.
+func isPrivateHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if addr, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil {
+		addr = addr.Unmap()
+		return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+			addr.IsUnspecified() || cgnatPrefix.Contains(addr)
+	}
+	if host == "localhost" || !strings.Contains(host, ".") {
+		return true
+	}
+	for _, suffix := range privateHostSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
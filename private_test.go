@@ -0,0 +1,38 @@
+package main
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Private addresses", func() {
+	Describe("isPrivateURL", func() {
+		DescribeTable("detects URLs Discord can't fetch",
+			func(rawURL string, private bool) {
+				Expect(isPrivateURL(rawURL)).To(Equal(private))
+			},
+			Entry("http localhost", "http://localhost:4533/art.jpg", true),
+			Entry("https localhost", "https://localhost/art.jpg", true),
+			Entry("loopback", "http://127.0.0.1:4533/art.jpg", true),
+			Entry("RFC 1918 10/8", "http://10.0.0.5/art.jpg", true),
+			Entry("RFC 1918 172.16/12", "http://172.20.1.1/art.jpg", true),
+			Entry("RFC 1918 192.168/16", "http://192.168.1.10:4533/art.jpg", true),
+			Entry("link-local", "http://169.254.10.1/art.jpg", true),
+			Entry("shared address space", "http://100.101.102.103/art.jpg", true),
+			Entry("IPv6 loopback", "http://[::1]:4533/art.jpg", true),
+			Entry("IPv6 ULA", "http://[fd12:3456:789a::1]/art.jpg", true),
+			Entry("IPv6 link-local", "http://[fe80::1]/art.jpg", true),
+			Entry("IPv4-mapped IPv6", "http://[::ffff:192.168.1.10]/art.jpg", true),
+			Entry("mDNS name", "http://navidrome.local:4533/art.jpg", true),
+			Entry("home.arpa", "http://music.home.arpa/art.jpg", true),
+			Entry("single-label name", "http://navidrome:4533/art.jpg", true),
+			Entry("trailing dot", "http://NAS.LOCAL./art.jpg", true),
+			Entry("no host", "/share/img/abc", true),
+			Entry("public name", "https://music.example.com/art.jpg", false),
+			Entry("public IPv4", "http://203.0.113.7/art.jpg", false),
+			Entry("172.32 is public", "http://172.32.0.1/art.jpg", false),
+			Entry("public IPv6", "http://[2001:db8::1]/art.jpg", false),
+			Entry("local as a label", "https://local.example.com/art.jpg", false),
+		)
+	})
+})
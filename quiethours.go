@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // Embedded zone database, WASM hosts have no /usr/share/zoneinfo
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// Configuration keys for quiet hours
+const (
+	quietHoursKey      = "quiethours"
+	quietHoursClearKey = "quiethoursclear"
+)
+
+// payloadQuietHours routes the scheduler callback that clears a live presence
+// when a quiet hours window begins. The schedule ID is "<username>-quiet".
+const payloadQuietHours = "quiet-hours"
+
+// quietWindow represents a recurring time window during which a user's presence is suppressed.
+// Start and End are "HH:MM" in the window's time zone. A window whose End is earlier than its
+// Start spans midnight, and Days refers to the day the window starts. An empty Days list means
+// every day of the week.
+type quietWindow struct {
+	Username string   `json:"username"`
+	Timezone string   `json:"timezone"`
+	Days     []string `json:"days"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+}
+
+// getQuietWindows loads the quiet hours windows configured for a user.
+func getQuietWindows(username string) []quietWindow {
+	windowsJSON, ok := pdk.GetConfig(quietHoursKey)
+	if !ok || windowsJSON == "" {
+		return nil
+	}
+
+	var all []quietWindow
+	if err := json.Unmarshal([]byte(windowsJSON), &all); err != nil {
+		pdk.Log(pdk.LogError, fmt.Sprintf("failed to parse quiet hours config: %v", err))
+		return nil
+	}
+
+	var windows []quietWindow
+	for _, w := range all {
+		if w.Username == username {
+			windows = append(windows, w)
+		}
+	}
+	return windows
+}
+
+// inQuietHours reports whether any of the user's quiet hours windows is active at t.
+func inQuietHours(username string, t time.Time) bool {
+	for _, w := range getQuietWindows(username) {
+		if w.activeAt(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextQuietStart returns the earliest upcoming start of any of the user's quiet hours windows.
+func nextQuietStart(windows []quietWindow, t time.Time) (time.Time, bool) {
+	var next time.Time
+	for _, w := range windows {
+		if start, ok := w.nextStart(t); ok && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// scheduleQuietHoursClear schedules a one-time callback that clears the presence when the
+// next quiet hours window begins before the current track would be cleared anyway.
+// remainingSeconds is the delay of that clear: the rest of the track, or the idle timeout
+// when its duration is unknown.
+func scheduleQuietHoursClear(username string, now time.Time, remainingSeconds int32) {
+	windows := getQuietWindows(username)
+	if len(windows) == 0 {
+		return
+	}
+	clearEnabled, _ := pdk.GetConfig(quietHoursClearKey)
+	if clearEnabled != "true" {
+		return
+	}
+
+	scheduleID := fmt.Sprintf("%s-quiet", username)
+	_ = host.SchedulerCancelSchedule(scheduleID)
+
+	start, ok := nextQuietStart(windows, now)
+	if !ok {
+		return
+	}
+	delay := int32(start.Sub(now).Seconds())
+	if delay >= remainingSeconds {
+		return
+	}
+	if delay < 1 {
+		delay = 1
+	}
+
+	if _, err := host.SchedulerScheduleOneTime(delay, payloadQuietHours, scheduleID); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to schedule quiet hours clear for user %s: %v", username, err))
+		return
+	}
+	pdk.Log(pdk.LogDebug, fmt.Sprintf("Scheduled quiet hours clear for user %s in %ds", username, delay))
+}
+
+// location returns the window's time zone, falling back to UTC when it is empty or unknown.
+func (w quietWindow) location() *time.Location {
+	if w.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Unknown quiet hours time zone %q, using UTC: %v", w.Timezone, err))
+		return time.UTC
+	}
+	return loc
+}
+
+// bounds returns the window's start and end as minutes since midnight.
+func (w quietWindow) bounds() (start, end int, ok bool) {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Invalid quiet hours start for user %s: %v", w.Username, err))
+		return 0, 0, false
+	}
+	end, err = parseClock(w.End)
+	if err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Invalid quiet hours end for user %s: %v", w.Username, err))
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// onDay reports whether the window starts on the given weekday.
+func (w quietWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if wd, ok := parseWeekday(d); ok && wd == day {
+			return true
+		}
+	}
+	return false
+}
+
+// activeAt reports whether t falls inside the window. Equal start and end cover the whole day.
+func (w quietWindow) activeAt(t time.Time) bool {
+	start, end, ok := w.bounds()
+	if !ok {
+		return false
+	}
+	local := t.In(w.location())
+	minute := local.Hour()*60 + local.Minute()
+	today := local.Weekday()
+	yesterday := (today + 6) % 7
+
+	switch {
+	case start == end:
+		return w.onDay(today)
+	case start < end:
+		return w.onDay(today) && minute >= start && minute < end
+	default: // Spans midnight
+		return (w.onDay(today) && minute >= start) || (w.onDay(yesterday) && minute < end)
+	}
+}
+
+// nextStart returns the first start of the window strictly after t, within the next week.
+func (w quietWindow) nextStart(t time.Time) (time.Time, bool) {
+	start, _, ok := w.bounds()
+	if !ok {
+		return time.Time{}, false
+	}
+	loc := w.location()
+	local := t.In(loc)
+	for i := 0; i <= 7; i++ {
+		day := local.AddDate(0, 0, i)
+		candidate := time.Date(day.Year(), day.Month(), day.Day(), start/60, start%60, 0, 0, loc)
+		if candidate.After(t) && w.onDay(candidate.Weekday()) {
+			return candidate, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseClock parses an "HH:MM" time of day into minutes since midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseWeekday parses a weekday name such as "Mon" or "monday".
+func parseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if name := strings.ToLower(d.String()); s == name || s == name[:3] {
+			return d, true
+		}
+	}
+	return 0, false
+}
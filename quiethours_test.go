@@ -0,0 +1,183 @@
+package main
+
+import (
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Quiet hours", func() {
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.SchedulerMock.ExpectedCalls = nil
+		host.SchedulerMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	// 2026-10-19 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	Describe("parseClock", func() {
+		DescribeTable("parses HH:MM",
+			func(input string, expected int, valid bool) {
+				minutes, err := parseClock(input)
+				if !valid {
+					Expect(err).To(HaveOccurred())
+					return
+				}
+				Expect(err).ToNot(HaveOccurred())
+				Expect(minutes).To(Equal(expected))
+			},
+			Entry("midnight", "00:00", 0, true),
+			Entry("evening", "22:30", 22*60+30, true),
+			Entry("surrounding spaces", " 09:15 ", 9*60+15, true),
+			Entry("out of range", "25:00", 0, false),
+			Entry("garbage", "late", 0, false),
+		)
+	})
+
+	Describe("parseWeekday", func() {
+		DescribeTable("accepts short and long names",
+			func(input string, expected time.Weekday, valid bool) {
+				day, ok := parseWeekday(input)
+				Expect(ok).To(Equal(valid))
+				if valid {
+					Expect(day).To(Equal(expected))
+				}
+			},
+			Entry("short", "Mon", time.Monday, true),
+			Entry("long lowercase", "saturday", time.Saturday, true),
+			Entry("upper", "SUN", time.Sunday, true),
+			Entry("too short", "M", time.Sunday, false),
+			Entry("unknown", "Funday", time.Sunday, false),
+			Entry("short name with a junk suffix", "monxyz", time.Sunday, false),
+			Entry("word starting with a short name", "sunshine", time.Sunday, false),
+			Entry("partial long name", "tues", time.Sunday, false),
+		)
+	})
+
+	Describe("activeAt", func() {
+		It("matches a same-day window on a listed weekday", func() {
+			w := quietWindow{Days: []string{"Mon", "Tue"}, Start: "09:00", End: "17:00"}
+			Expect(w.activeAt(at(19, 9, 0))).To(BeTrue())
+			Expect(w.activeAt(at(19, 16, 59))).To(BeTrue())
+			Expect(w.activeAt(at(19, 17, 0))).To(BeFalse())
+			Expect(w.activeAt(at(21, 10, 0))).To(BeFalse()) // Wednesday
+		})
+
+		It("matches a window spanning midnight using the start day", func() {
+			w := quietWindow{Days: []string{"Fri"}, Start: "23:00", End: "07:00"}
+			Expect(w.activeAt(at(23, 23, 30))).To(BeTrue()) // Friday night
+			Expect(w.activeAt(at(24, 6, 59))).To(BeTrue())  // Saturday morning
+			Expect(w.activeAt(at(24, 7, 0))).To(BeFalse())
+			Expect(w.activeAt(at(23, 6, 0))).To(BeFalse()) // Friday morning belongs to Thursday
+		})
+
+		It("treats an empty day list as every day", func() {
+			w := quietWindow{Start: "01:00", End: "02:00"}
+			for day := 19; day <= 25; day++ {
+				Expect(w.activeAt(at(day, 1, 30))).To(BeTrue())
+			}
+		})
+
+		It("treats equal start and end as the whole day", func() {
+			w := quietWindow{Days: []string{"Sun"}, Start: "00:00", End: "00:00"}
+			Expect(w.activeAt(at(25, 12, 0))).To(BeTrue())
+			Expect(w.activeAt(at(24, 12, 0))).To(BeFalse())
+		})
+
+		It("evaluates the window in its time zone", func() {
+			w := quietWindow{Timezone: "America/New_York", Start: "22:00", End: "23:00"}
+			// 02:30 UTC is 22:30 EDT on the previous day
+			Expect(w.activeAt(at(20, 2, 30))).To(BeTrue())
+			Expect(w.activeAt(at(19, 22, 30))).To(BeFalse())
+		})
+
+		It("falls back to UTC for an unknown time zone", func() {
+			w := quietWindow{Timezone: "Mars/Olympus", Start: "22:00", End: "23:00"}
+			Expect(w.activeAt(at(19, 22, 30))).To(BeTrue())
+		})
+
+		It("is never active with invalid bounds", func() {
+			w := quietWindow{Start: "bad", End: "23:00"}
+			Expect(w.activeAt(at(19, 22, 30))).To(BeFalse())
+		})
+	})
+
+	Describe("nextStart", func() {
+		It("returns today's start when it is still ahead", func() {
+			w := quietWindow{Start: "22:00", End: "07:00"}
+			start, ok := w.nextStart(at(19, 20, 0))
+			Expect(ok).To(BeTrue())
+			Expect(start).To(BeTemporally("==", at(19, 22, 0)))
+		})
+
+		It("skips to the next listed weekday", func() {
+			w := quietWindow{Days: []string{"Thu"}, Start: "09:00", End: "17:00"}
+			start, ok := w.nextStart(at(22, 10, 0)) // Thursday, already past the start
+			Expect(ok).To(BeTrue())
+			Expect(start).To(BeTemporally("==", at(29, 9, 0)))
+		})
+
+		It("picks the earliest start across windows", func() {
+			windows := []quietWindow{
+				{Start: "23:00", End: "07:00"},
+				{Start: "21:00", End: "22:00"},
+			}
+			start, ok := nextQuietStart(windows, at(19, 20, 0))
+			Expect(ok).To(BeTrue())
+			Expect(start).To(BeTemporally("==", at(19, 21, 0)))
+		})
+	})
+
+	Describe("inQuietHours", func() {
+		It("only considers windows for the given user", func() {
+			pdk.PDKMock.On("GetConfig", quietHoursKey).Return(`[{"username":"other","start":"00:00","end":"00:00"}]`, true)
+			Expect(inQuietHours("testuser", at(19, 12, 0))).To(BeFalse())
+			Expect(inQuietHours("other", at(19, 12, 0))).To(BeTrue())
+		})
+
+		It("ignores invalid configuration", func() {
+			pdk.PDKMock.On("GetConfig", quietHoursKey).Return(`not json`, true)
+			Expect(inQuietHours("testuser", at(19, 12, 0))).To(BeFalse())
+		})
+	})
+
+	Describe("scheduleQuietHoursClear", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("GetConfig", quietHoursKey).Return(`[{"username":"testuser","start":"22:00","end":"07:00"}]`, true)
+		})
+
+		It("schedules a clear when a window begins during the track", func() {
+			pdk.PDKMock.On("GetConfig", quietHoursClearKey).Return("true", true)
+			host.SchedulerMock.On("CancelSchedule", "testuser-quiet").Return(nil)
+			host.SchedulerMock.On("ScheduleOneTime", int32(120), payloadQuietHours, "testuser-quiet").Return("testuser-quiet", nil)
+
+			scheduleQuietHoursClear("testuser", at(19, 21, 58), 300)
+			host.SchedulerMock.AssertCalled(GinkgoT(), "ScheduleOneTime", int32(120), payloadQuietHours, "testuser-quiet")
+		})
+
+		It("does not schedule when the track ends before the window", func() {
+			pdk.PDKMock.On("GetConfig", quietHoursClearKey).Return("true", true)
+			host.SchedulerMock.On("CancelSchedule", "testuser-quiet").Return(nil)
+
+			scheduleQuietHoursClear("testuser", at(19, 20, 0), 300)
+			host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("does nothing when the clear hook is disabled", func() {
+			pdk.PDKMock.On("GetConfig", quietHoursClearKey).Return("", false)
+
+			scheduleQuietHoursClear("testuser", at(19, 21, 58), 300)
+			host.SchedulerMock.AssertNotCalled(GinkgoT(), "CancelSchedule", mock.Anything)
+			host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+		})
+	})
+})
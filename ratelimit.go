@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// payloadImageRetry routes the scheduler callback that sends an activity again once Discord
+// accepts external asset requests. The schedule ID is "<username>-images".
+const payloadImageRetry = "image-retry"
+
+const (
+	defaultRetryAfter  int64 = 5  // Backoff when a 429 carries no delay
+	maxImageRetryDelay int64 = 60 // Longer rate limits fall back to the default image
+	imageRetryTTL      int64 = 5 * 60
+)
+
+// rateLimitError is returned while Discord rate limits external asset requests.
+type rateLimitError struct {
+	RetryAfter int64 // Seconds until requests are accepted again
+}
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by Discord, retry in %ds", e.RetryAfter)
+}
+
+// rateLimitKey returns the cache key of the application's external assets backoff.
+func rateLimitKey(clientID string) string {
+	return fmt.Sprintf("discord.ratelimit.%s", clientID)
+}
+
+// checkRateLimit returns a rateLimitError while a backoff is cached for the application.
+func checkRateLimit(clientID string) error {
+	cached, exists, err := host.CacheGetString(rateLimitKey(clientID))
+	if err != nil || !exists {
+		return nil
+	}
+	until, err := strconv.ParseInt(cached, 10, 64)
+	if err != nil {
+		return nil
+	}
+	if wait := until - time.Now().Unix(); wait > 0 {
+		return &rateLimitError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// handleRateLimit caches a backoff from Discord's rate limit headers. It returns a
+// rateLimitError for 429 responses, and nil for others, which only start a backoff when they
+// used up the bucket (X-RateLimit-Remaining is 0).
+func handleRateLimit(clientID string, resp *host.HTTPResponse) error {
+	limited := resp.StatusCode == 429
+	if !limited && headerValue(resp.Headers, "X-RateLimit-Remaining") != "0" {
+		return nil
+	}
+
+	retryAfter := parseSeconds(headerValue(resp.Headers, "Retry-After"))
+	if retryAfter == 0 {
+		retryAfter = parseSeconds(headerValue(resp.Headers, "X-RateLimit-Reset-After"))
+	}
+	if retryAfter == 0 && limited {
+		var body struct {
+			RetryAfter float64 `json:"retry_after"`
+		}
+		if json.Unmarshal(resp.Body, &body) == nil {
+			retryAfter = int64(math.Ceil(body.RetryAfter))
+		}
+	}
+	if retryAfter == 0 {
+		if !limited {
+			return nil
+		}
+		retryAfter = defaultRetryAfter
+	}
+
+	_ = host.CacheSetString(rateLimitKey(clientID), strconv.FormatInt(time.Now().Unix()+retryAfter, 10), retryAfter)
+	pdk.Log(pdk.LogWarn, fmt.Sprintf("Discord external assets rate limit reached, backing off for %ds", retryAfter))
+	if !limited {
+		return nil
+	}
+	return &rateLimitError{RetryAfter: retryAfter}
+}
+
+// parseSeconds parses a delay in (possibly fractional) seconds, rounded up. Invalid or
+// negative values return 0.
+func parseSeconds(value string) int64 {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(seconds))
+}
+
+// ============================================================================
+// Delayed retries
+// ============================================================================
+
+// imageRetry is an activity held back while Discord rate limits its images.
+type imageRetry struct {
+	ClientID string   `json:"clientId"`
+	Activity activity `json:"activity"`
+}
+
+func imageRetryScheduleID(username string) string {
+	return fmt.Sprintf("%s-images", username)
+}
+
+func imageRetryKey(username string) string {
+	return fmt.Sprintf("discord.activity.retry.%s", username)
+}
+
+// scheduleImageRetry holds the activity back and schedules sending it after the backoff.
+// It returns false when the activity should be sent now instead, because the backoff is too
+// long or the retry can't be scheduled.
+func scheduleImageRetry(clientID, username string, data activity, retryAfter int64) bool {
+	if retryAfter > maxImageRetryDelay {
+		return false
+	}
+	payload, err := json.Marshal(imageRetry{ClientID: clientID, Activity: data})
+	if err != nil {
+		return false
+	}
+	if err := host.CacheSetString(imageRetryKey(username), string(payload), imageRetryTTL); err != nil {
+		return false
+	}
+	if _, err := host.SchedulerScheduleOneTime(int32(retryAfter), payloadImageRetry, imageRetryScheduleID(username)); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to schedule image retry for user %s: %v", username, err))
+		_ = host.CacheRemove(imageRetryKey(username))
+		return false
+	}
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Images rate limited, sending activity for user %s in %ds", username, retryAfter))
+	return true
+}
+
+// cancelImageRetry drops a held back activity, once a newer one replaces it or the
+// presence is cleared.
+func cancelImageRetry(username string) {
+	_ = host.SchedulerCancelSchedule(imageRetryScheduleID(username))
+	_ = host.CacheRemove(imageRetryKey(username))
+}
+
+// handleImageRetryCallback sends the held back activity. A second rate limit falls back to
+// the default image rather than waiting again.
+func (r *discordRPC) handleImageRetryCallback(username string) error {
+	cached, exists, err := host.CacheGetString(imageRetryKey(username))
+	if err != nil || !exists {
+		return nil
+	}
+	_ = host.CacheRemove(imageRetryKey(username))
+	var retry imageRetry
+	if err := json.Unmarshal([]byte(cached), &retry); err != nil {
+		return fmt.Errorf("failed to parse image retry: %w", err)
+	}
+
+	_, users, err := getConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get config: %w", err)
+	}
+	token, ok := users[username]
+	if !ok {
+		return nil
+	}
+	return r.updateActivity(retry.ClientID, username, token, retry.Activity, false)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Discord rate limits", func() {
+	var r *discordRPC
+
+	track := activity{
+		Application: "client123",
+		Name:        "Test Song",
+		Type:        2,
+		State:       "Test Artist",
+		Details:     "Test Album",
+		Assets: activityAssets{
+			LargeImage: "https://example.com/art.jpg",
+			LargeText:  "Test Album",
+			SmallImage: navidromeLogoURL,
+			SmallText:  "Navidrome",
+		},
+	}
+
+	BeforeEach(func() {
+		r = &discordRPC{}
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.WebSocketMock.ExpectedCalls = nil
+		host.WebSocketMock.Calls = nil
+		host.SchedulerMock.ExpectedCalls = nil
+		host.SchedulerMock.Calls = nil
+		host.HTTPMock.ExpectedCalls = nil
+		host.HTTPMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		host.CacheMock.On("GetString", artworkExpiryArg).Return("", false, nil).Maybe()
+		host.CacheMock.On("GetString", discordImageKey).Return("", false, nil).Maybe()
+		host.CacheMock.On("SetString", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+		host.CacheMock.On("Remove", mock.Anything).Return(nil).Maybe()
+	})
+
+	Describe("handleRateLimit", func() {
+		It("returns a rateLimitError and caches the backoff on 429", func() {
+			err := handleRateLimit("client123", &host.HTTPResponse{StatusCode: 429, Headers: map[string]string{"retry-after": "12"}})
+
+			var rateLimited *rateLimitError
+			Expect(errors.As(err, &rateLimited)).To(BeTrue())
+			Expect(rateLimited.RetryAfter).To(Equal(int64(12)))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", rateLimitKey("client123"), mock.Anything, int64(12))
+		})
+
+		It("reads the delay from the body when there is no header", func() {
+			err := handleRateLimit("client123", &host.HTTPResponse{StatusCode: 429, Body: []byte(`{"message":"You are being rate limited.","retry_after":2.4}`)})
+
+			var rateLimited *rateLimitError
+			Expect(errors.As(err, &rateLimited)).To(BeTrue())
+			Expect(rateLimited.RetryAfter).To(Equal(int64(3)))
+		})
+
+		It("falls back to the default delay", func() {
+			err := handleRateLimit("client123", &host.HTTPResponse{StatusCode: 429})
+
+			var rateLimited *rateLimitError
+			Expect(errors.As(err, &rateLimited)).To(BeTrue())
+			Expect(rateLimited.RetryAfter).To(Equal(defaultRetryAfter))
+		})
+
+		It("starts a backoff when the bucket is used up", func() {
+			err := handleRateLimit("client123", &host.HTTPResponse{StatusCode: 200, Headers: map[string]string{
+				"X-RateLimit-Remaining":   "0",
+				"X-RateLimit-Reset-After": "7.5",
+			}})
+
+			Expect(err).ToNot(HaveOccurred())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", rateLimitKey("client123"), mock.Anything, int64(8))
+		})
+
+		It("ignores responses with requests left", func() {
+			err := handleRateLimit("client123", &host.HTTPResponse{StatusCode: 200, Headers: map[string]string{"X-RateLimit-Remaining": "3"}})
+
+			Expect(err).ToNot(HaveOccurred())
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", rateLimitKey("client123"), mock.Anything, mock.Anything)
+		})
+	})
+
+	Describe("requestExternalAssets", func() {
+		It("doesn't send requests while backing off", func() {
+			until := strconv.FormatInt(time.Now().Unix()+30, 10)
+			host.CacheMock.On("GetString", rateLimitKey("client123")).Return(until, true, nil)
+
+			_, err := r.requestExternalAssets([]string{"https://example.com/art.jpg"}, "client123", "token123")
+			var rateLimited *rateLimitError
+			Expect(errors.As(err, &rateLimited)).To(BeTrue())
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+
+		It("backs off per application", func() {
+			until := strconv.FormatInt(time.Now().Unix()+30, 10)
+			host.CacheMock.On("GetString", rateLimitKey("client123")).Return(until, true, nil)
+			host.CacheMock.On("GetString", rateLimitKey("other")).Return("", false, nil)
+			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/art"}]`)}, nil)
+
+			paths, err := r.requestExternalAssets([]string{"https://example.com/art.jpg"}, "other", "token123")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(paths).To(Equal([]string{"external/art"}))
+		})
+	})
+
+	Describe("discordAssetCacheKey", func() {
+		It("scopes assets to the application", func() {
+			Expect(discordAssetCacheKey("a", "https://example.com/art.jpg")).ToNot(Equal(discordAssetCacheKey("b", "https://example.com/art.jpg")))
+		})
+	})
+
+	Describe("sendActivity", func() {
+		BeforeEach(func() {
+			host.CacheMock.On("GetString", rateLimitKey("client123")).Return("", false, nil).Maybe()
+			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{
+				StatusCode: 429,
+				Headers:    map[string]string{"Retry-After": "10"},
+			}, nil).Once()
+		})
+
+		It("holds the activity back until the rate limit ends", func() {
+			host.SchedulerMock.On("ScheduleOneTime", int32(10), payloadImageRetry, "testuser-images").Return("testuser-images", nil)
+
+			err := r.sendActivity("client123", "testuser", "token123", track)
+			Expect(err).ToNot(HaveOccurred())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", imageRetryKey("testuser"), mock.MatchedBy(func(payload string) bool {
+				var retry imageRetry
+				return json.Unmarshal([]byte(payload), &retry) == nil && retry.ClientID == "client123" &&
+					retry.Activity.Assets.LargeImage == "https://example.com/art.jpg"
+			}), imageRetryTTL)
+			host.SchedulerMock.AssertExpectations(GinkgoT())
+			host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+		})
+
+		It("sends the activity without images when the retry can't be scheduled", func() {
+			host.SchedulerMock.On("ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything).Return("", errors.New("scheduler unavailable"))
+			host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+				return strings.Contains(msg, `"op":3`) && !strings.Contains(msg, `"large_image":"mp:`)
+			})).Return(nil)
+
+			err := r.sendActivity("client123", "testuser", "token123", track)
+			Expect(err).ToNot(HaveOccurred())
+			host.CacheMock.AssertCalled(GinkgoT(), "Remove", imageRetryKey("testuser"))
+			host.WebSocketMock.AssertExpectations(GinkgoT())
+		})
+	})
+
+	Describe("scheduleImageRetry", func() {
+		It("doesn't wait out long rate limits", func() {
+			Expect(scheduleImageRetry("client123", "testuser", track, maxImageRetryDelay+1)).To(BeFalse())
+			host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+		})
+	})
+
+	Describe("handleImageRetryCallback", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("client123", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"token123"}]`, true)
+		})
+
+		It("sends the held back activity", func() {
+			payload, _ := json.Marshal(imageRetry{ClientID: "client123", Activity: track})
+			host.CacheMock.On("GetString", imageRetryKey("testuser")).Return(string(payload), true, nil)
+			host.CacheMock.On("GetString", rateLimitKey("client123")).Return("", false, nil)
+			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/art"},{"external_asset_path":"external/logo"}]`)}, nil)
+			host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+				return strings.Contains(msg, `"large_image":"mp:external/art"`)
+			})).Return(nil)
+
+			Expect(r.handleImageRetryCallback("testuser")).To(Succeed())
+			host.CacheMock.AssertCalled(GinkgoT(), "Remove", imageRetryKey("testuser"))
+			host.WebSocketMock.AssertExpectations(GinkgoT())
+		})
+
+		It("falls back to the default image when still rate limited", func() {
+			payload, _ := json.Marshal(imageRetry{ClientID: "client123", Activity: track})
+			host.CacheMock.On("GetString", imageRetryKey("testuser")).Return(string(payload), true, nil)
+			host.CacheMock.On("GetString", rateLimitKey("client123")).Return(strconv.FormatInt(time.Now().Unix()+10, 10), true, nil)
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+			Expect(r.handleImageRetryCallback("testuser")).To(Succeed())
+			host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+			host.WebSocketMock.AssertCalled(GinkgoT(), "SendText", "testuser", mock.Anything)
+		})
+
+		It("does nothing when the activity was replaced", func() {
+			host.CacheMock.On("GetString", imageRetryKey("testuser")).Return("", false, nil)
+
+			Expect(r.handleImageRetryCallback("testuser")).To(Succeed())
+			host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+		})
+	})
+})
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// Configuration key for the rating indicator
+const ratingDisplayKey = "ratingdisplay"
+
+// Rating display options
+const (
+	ratingDisplayNone      = "None"      // Only available through the {rating} placeholder
+	ratingDisplaySmallText = "SmallText" // Shown as the small image hover text
+)
+
+// ratingIndicator renders the user's starred status and rating, e.g. "♥ Loved", "★★★★"
+// or "♥ ★★★★". Returns "" when the song is neither starred nor rated.
+func ratingIndicator(song *subsonicSong) string {
+	if song == nil {
+		return ""
+	}
+	loved := song.Starred != ""
+	rating := min(max(song.UserRating, 0), 5)
+
+	switch {
+	case loved && rating > 0:
+		return "♥ " + strings.Repeat("★", rating)
+	case loved:
+		return "♥ Loved"
+	case rating > 0:
+		return strings.Repeat("★", rating)
+	}
+	return ""
+}
+
+// getRatingIndicator looks up the rating indicator for the user's current track, if it is
+// shown anywhere. The second return value reports whether it should replace the small image text.
+func getRatingIndicator(username, trackID string) (indicator string, smallText bool) {
+	display, _ := pdk.GetConfig(ratingDisplayKey)
+	smallText = display == ratingDisplaySmallText
+	if !smallText && !templatesUse(placeholderRating) {
+		return "", false
+	}
+
+	song, err := getSong(username, trackID)
+	if err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to get rating for track %s: %v", trackID, err))
+		return "", smallText
+	}
+	return ratingIndicator(song), smallText
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rating", func() {
+	Describe("ratingIndicator", func() {
+		DescribeTable("renders starred status and rating",
+			func(song *subsonicSong, expected string) {
+				Expect(ratingIndicator(song)).To(Equal(expected))
+			},
+			Entry("nil song", nil, ""),
+			Entry("neither starred nor rated", &subsonicSong{}, ""),
+			Entry("starred only", &subsonicSong{Starred: "2024-01-01T00:00:00Z"}, "♥ Loved"),
+			Entry("rated only", &subsonicSong{UserRating: 4}, "★★★★"),
+			Entry("starred and rated", &subsonicSong{Starred: "2024-01-01T00:00:00Z", UserRating: 2}, "♥ ★★"),
+			Entry("rating clamped to five", &subsonicSong{UserRating: 9}, "★★★★★"),
+		)
+	})
+
+	Describe("getRatingIndicator", func() {
+		BeforeEach(func() {
+			pdk.ResetMock()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.Calls = nil
+			host.SubsonicAPIMock.ExpectedCalls = nil
+			host.SubsonicAPIMock.Calls = nil
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		})
+
+		It("does not look up the song when the rating is not shown", func() {
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+
+			indicator, smallText := getRatingIndicator("testuser", "track1")
+			Expect(indicator).To(BeEmpty())
+			Expect(smallText).To(BeFalse())
+			host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "Call", mock.Anything)
+		})
+
+		It("looks up the song when a template uses the placeholder", func() {
+			pdk.PDKMock.On("GetConfig", smallTextTemplateKey).Return("{rating}", true)
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			host.CacheMock.On("GetString", "subsonic.song.testuser.track1").Return(`{"id":"track1","userRating":3}`, true, nil)
+
+			indicator, smallText := getRatingIndicator("testuser", "track1")
+			Expect(indicator).To(Equal("★★★"))
+			Expect(smallText).To(BeFalse())
+		})
+
+		It("returns no indicator when the lookup fails", func() {
+			pdk.PDKMock.On("GetConfig", ratingDisplayKey).Return(ratingDisplaySmallText, true)
+			host.CacheMock.On("GetString", "subsonic.song.testuser.track1").Return("", false, nil)
+			host.SubsonicAPIMock.On("Call", mock.Anything).Return("", errors.New("boom"))
+
+			indicator, smallText := getRatingIndicator("testuser", "track1")
+			Expect(indicator).To(BeEmpty())
+			Expect(smallText).To(BeTrue())
+		})
+	})
+})
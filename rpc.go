@@ -12,8 +12,10 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/navidrome/navidrome/plugins/pdk/go/host"
 	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
@@ -57,21 +59,21 @@ const heartbeatInterval = 41 // Heartbeat interval in seconds
 
 // activity represents a Discord activity sent via Gateway opcode 3.
 type activity struct {
-	Name              string             `json:"name"`
-	Type              int                `json:"type"`
-	Details           string             `json:"details"`
-	DetailsURL        string             `json:"details_url,omitempty"`
-	State             string             `json:"state"`
-	StateURL          string             `json:"state_url,omitempty"`
-	Application       string             `json:"application_id"`
-	StatusDisplayType int                `json:"status_display_type"`
-	Timestamps        activityTimestamps `json:"timestamps"`
-	Assets            activityAssets     `json:"assets"`
+	Name              string              `json:"name"`
+	Type              int                 `json:"type"`
+	Details           string              `json:"details"`
+	DetailsURL        string              `json:"details_url,omitempty"`
+	State             string              `json:"state"`
+	StateURL          string              `json:"state_url,omitempty"`
+	Application       string              `json:"application_id"`
+	StatusDisplayType int                 `json:"status_display_type"`
+	Timestamps        *activityTimestamps `json:"timestamps,omitempty"`
+	Assets            activityAssets      `json:"assets"`
 }
 
 type activityTimestamps struct {
-	Start int64 `json:"start"`
-	End   int64 `json:"end"`
+	Start int64 `json:"start,omitempty"`
+	End   int64 `json:"end,omitempty"`
 }
 
 type activityAssets struct {
@@ -135,100 +137,195 @@ func (r *discordRPC) OnClose(input websocket.OnCloseRequest) error {
 // Image Processing
 // ============================================================================
 
-// processImage processes an image URL for Discord. Returns the processed image
-// string (mp:prefixed) or an error. No fallback logic — the caller handles retries.
-func (r *discordRPC) processImage(imageURL, clientID, token string, ttl int64) (string, error) {
-	if imageURL == "" {
-		return "", fmt.Errorf("image URL is empty")
-	}
+// discordAssetCacheKey returns the cache key of the Discord asset processed from imageURL.
+// Assets are only valid for the application they were issued to, so keys include its ID.
+func discordAssetCacheKey(clientID, imageURL string) string {
+	return fmt.Sprintf("discord.image.%s.%s", clientID, hashKey(imageURL))
+}
 
-	if strings.HasPrefix(imageURL, "mp:") {
-		return imageURL, nil
-	}
+// imageAsset is an image URL to process for Discord, and how long its asset is cached.
+type imageAsset struct {
+	URL string
+	TTL int64
+}
 
-	// Check cache first
-	cacheKey := "discord.image." + hashKey(imageURL)
-	cachedValue, exists, err := host.CacheGetString(cacheKey)
-	if err == nil && exists {
-		pdk.Log(pdk.LogDebug, fmt.Sprintf("Cache hit for image URL: %s", imageURL))
-		return cachedValue, nil
+// maxExternalAssetURLs is the most URLs Discord accepts in one external-assets request.
+const maxExternalAssetURLs = 2
+
+// processImages processes image URLs for Discord. Cached assets are reused, and all other
+// URLs are sent in external-assets requests of up to maxExternalAssetURLs, whose results are
+// matched back to the URLs by index. Images are sent in order, so the first two share a
+// request. assets[i] is the processed image (mp: prefixed) for images[i], or "" with
+// errs[i] set. No fallback logic — the caller picks from what was processed.
+func (r *discordRPC) processImages(images []imageAsset, clientID, token string) (assets []string, errs []error) {
+	assets = make([]string, len(images))
+	errs = make([]error, len(images))
+
+	// Positions of each URL still to process, so duplicates are only sent once
+	pending := map[string][]int{}
+	var urls []string
+	for i, img := range images {
+		switch {
+		case img.URL == "":
+			errs[i] = fmt.Errorf("image URL is empty")
+		case strings.HasPrefix(img.URL, "mp:"):
+			assets[i] = img.URL
+		case pending[img.URL] != nil:
+			pending[img.URL] = append(pending[img.URL], i)
+		default:
+			// Check cache and resolution store first
+			if cachedValue, exists := tieredGet(discordAssetCacheKey(clientID, img.URL), img.TTL); exists {
+				pdk.Log(pdk.LogDebug, fmt.Sprintf("Cache hit for image URL: %s", img.URL))
+				assets[i] = cachedValue
+				continue
+			}
+			pending[img.URL] = []int{i}
+			urls = append(urls, img.URL)
+		}
+	}
+	if len(urls) == 0 {
+		return assets, errs
+	}
+
+	// Discord accepts only a few URLs per request, so a failed chunk only fails its own images
+	for start := 0; start < len(urls); start += maxExternalAssetURLs {
+		chunk := urls[start:min(start+maxExternalAssetURLs, len(urls))]
+		paths, err := r.requestExternalAssets(chunk, clientID, token)
+		for n, imageURL := range chunk {
+			processedImage, imgErr := "", err
+			if err == nil {
+				switch {
+				case n >= len(paths):
+					imgErr = fmt.Errorf("no data returned for image")
+				case paths[n] == "":
+					imgErr = fmt.Errorf("empty external_asset_path for image")
+				default:
+					processedImage = "mp:" + paths[n]
+				}
+			}
+			for _, i := range pending[imageURL] {
+				assets[i], errs[i] = processedImage, imgErr
+			}
+			if imgErr == nil {
+				// Duplicates are cached with the TTL of their first position
+				r.cacheImageAsset(clientID, imageURL, processedImage, images[pending[imageURL][0]].TTL)
+			}
+		}
 	}
+	return assets, errs
+}
 
-	// Process via Discord API
-	body := fmt.Sprintf(`{"urls":[%q]}`, imageURL)
+// requestExternalAssets registers up to maxExternalAssetURLs image URLs with Discord in one
+// request, and returns their external asset paths in the same order. While the application
+// is rate limited, it returns a rateLimitError without sending anything.
+func (r *discordRPC) requestExternalAssets(urls []string, clientID, token string) ([]string, error) {
+	if err := checkRateLimit(clientID); err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(map[string][]string{"urls": urls})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image request: %w", err)
+	}
 	resp, err := host.HTTPSend(host.HTTPRequest{
 		Method:  "POST",
 		URL:     fmt.Sprintf("https://discord.com/api/v9/applications/%s/external-assets", clientID),
 		Headers: map[string]string{"Authorization": token, "Content-Type": "application/json"},
-		Body:    []byte(body),
+		Body:    body,
 	})
 	if err != nil {
 		pdk.Log(pdk.LogWarn, fmt.Sprintf("HTTP request failed for image processing: %v", err))
-		return "", fmt.Errorf("failed to process image: %w", err)
+		return nil, fmt.Errorf("failed to process image: %w", err)
+	}
+	if err := handleRateLimit(clientID, resp); err != nil {
+		return nil, err
 	}
 	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("failed to process image: HTTP %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to process image: HTTP %d", resp.StatusCode)
 	}
 
 	var data []map[string]string
 	if err := json.Unmarshal(resp.Body, &data); err != nil {
-		return "", fmt.Errorf("failed to unmarshal image response: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal image response: %w", err)
 	}
-
-	if len(data) == 0 {
-		return "", fmt.Errorf("no data returned for image")
+	paths := make([]string, len(data))
+	for i, d := range data {
+		paths[i] = d["external_asset_path"]
 	}
+	return paths, nil
+}
 
-	image := data[0]["external_asset_path"]
-	if image == "" {
-		return "", fmt.Errorf("empty external_asset_path for image")
+// cacheImageAsset caches the asset processed from imageURL for ttl. The asset is only as
+// good as the file behind it, so temporary uploads cap its TTL.
+func (r *discordRPC) cacheImageAsset(clientID, imageURL, processedImage string, ttl int64) {
+	if expiresAt, ok := artworkExpiresAt(imageURL); ok {
+		ttl = min(ttl, expiresAt-time.Now().Unix())
+		if ttl <= 0 {
+			return
+		}
 	}
-
-	processedImage := fmt.Sprintf("mp:%s", image)
-
-	_ = host.CacheSetString(cacheKey, processedImage, ttl)
+	tieredSet(discordAssetCacheKey(clientID, imageURL), processedImage, ttl, ttl)
 	pdk.Log(pdk.LogDebug, fmt.Sprintf("Cached processed image URL for %s (TTL: %ds)", imageURL, ttl))
-
-	return processedImage, nil
 }
 
 // ============================================================================
 // Activity Management
 // ============================================================================
 
-// sendActivity sends an activity update to Discord.
+// sendActivity sends an activity update to Discord. When Discord rate limits its images,
+// the update is held back and sent again shortly (see scheduleImageRetry).
 func (r *discordRPC) sendActivity(clientID, username, token string, data activity) error {
+	return r.updateActivity(clientID, username, token, data, true)
+}
+
+// updateActivity sends an activity update to Discord, holding it back for a retry when its
+// images are rate limited and allowRetry is set.
+func (r *discordRPC) updateActivity(clientID, username, token string, data activity, allowRetry bool) error {
 	pdk.Log(pdk.LogInfo, fmt.Sprintf("Sending activity for user %s: %s - %s", username, data.Details, data.State))
 
-	// Try track artwork first, fall back to Navidrome logo
+	// Enforce Discord's field limits so one oversized value doesn't reject the whole update
+	data = normalizeActivity(username, data)
+
+	// Process the track artwork, the Navidrome logo it falls back to and the small image
+	// together, so cache misses cost as few requests as possible
+	images := []imageAsset{
+		{URL: data.Assets.LargeImage, TTL: imageCacheTTL},
+		{URL: navidromeLogoURL, TTL: defaultImageCacheTTL},
+	}
+	if data.Assets.SmallImage != "" {
+		images = append(images, imageAsset{URL: data.Assets.SmallImage, TTL: defaultImageCacheTTL})
+	}
+	assets, errs := r.processImages(images, clientID, token)
+	var rateLimited *rateLimitError
+	if allowRetry && errors.As(errs[0], &rateLimited) && scheduleImageRetry(clientID, username, data, rateLimited.RetryAfter) {
+		return nil
+	}
+
+	// Use track artwork first, fall back to Navidrome logo
 	usingDefaultImage := false
-	processedImage, err := r.processImage(data.Assets.LargeImage, clientID, token, imageCacheTTL)
-	if err != nil {
-		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to process track image for user %s: %v, falling back to default", username, err))
-		processedImage, err = r.processImage(navidromeLogoURL, clientID, token, defaultImageCacheTTL)
-		if err != nil {
-			pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to process default image for user %s: %v, continuing without image", username, err))
+	if errs[0] == nil {
+		data.Assets.LargeImage = assets[0]
+	} else {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to process track image for user %s: %v, falling back to default", username, errs[0]))
+		if errs[1] != nil {
+			pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to process default image for user %s: %v, continuing without image", username, errs[1]))
 			data.Assets.LargeImage = ""
 		} else {
-			data.Assets.LargeImage = processedImage
+			data.Assets.LargeImage = assets[1]
 			usingDefaultImage = true
 		}
-	} else {
-		data.Assets.LargeImage = processedImage
 	}
 
-	// Only show SmallImage (Navidrome logo overlay) when LargeImage is actual track artwork
+	// Only show SmallImage (the overlay) when LargeImage is actual track artwork
 	if usingDefaultImage || data.Assets.LargeImage == "" {
 		data.Assets.SmallImage = ""
 		data.Assets.SmallText = ""
 	} else if data.Assets.SmallImage != "" {
-		processedSmall, err := r.processImage(data.Assets.SmallImage, clientID, token, defaultImageCacheTTL)
-		if err != nil {
-			pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to process small image for user %s: %v", username, err))
+		if errs[2] != nil {
+			pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to process small image for user %s: %v", username, errs[2]))
 			data.Assets.SmallImage = ""
 			data.Assets.SmallText = ""
 		} else {
-			data.Assets.SmallImage = processedSmall
+			data.Assets.SmallImage = assets[2]
 		}
 	}
 
@@ -411,6 +508,15 @@ func (r *discordRPC) handleWebSocketMessage(connectionID, message string) error
 			return fmt.Errorf("failed to store sequence number for user %s: %w", connectionID, err)
 		}
 	}
+
+	// The READY event identifies the logged in user, whose avatar can be the small image.
+	// An activity sent before it was known is sent again with the avatar.
+	if msg["t"] == "READY" {
+		cacheDiscordAvatar(connectionID, message)
+		if err := r.sendAvatarActivity(connectionID); err != nil {
+			return fmt.Errorf("failed to send activity with avatar: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/navidrome/navidrome/plugins/pdk/go/host"
 	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
@@ -27,6 +30,8 @@ var _ = Describe("discordRPC", func() {
 		host.SchedulerMock.Calls = nil
 		host.HTTPMock.ExpectedCalls = nil
 		host.HTTPMock.Calls = nil
+		host.CacheMock.On("GetString", artworkExpiryArg).Return("", false, nil).Maybe()
+		host.CacheMock.On("GetString", rateLimitKey("client123")).Return("", false, nil).Maybe()
 	})
 
 	Describe("sendMessage", func() {
@@ -189,6 +194,59 @@ var _ = Describe("discordRPC", func() {
 				Expect(err).ToNot(HaveOccurred())
 			})
 
+			It("remembers the user's avatar from READY", func() {
+				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+				host.CacheMock.On("SetInt", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				host.CacheMock.On("SetString", "discord.avatar.testuser", "https://cdn.discordapp.com/avatars/123/abc.png?size=128", discordAvatarTTL).Return(nil)
+				host.CacheMock.On("GetString", avatarActivityKey("testuser")).Return("", false, nil)
+
+				err := r.OnTextMessage(websocket.OnTextMessageRequest{
+					ConnectionID: "testuser",
+					Message:      `{"op":0,"t":"READY","s":1,"d":{"user":{"id":"123","avatar":"abc"}}}`,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				host.CacheMock.AssertExpectations(GinkgoT())
+			})
+
+			It("sends the activity held for the avatar again once READY caches it", func() {
+				avatarURL := "https://cdn.discordapp.com/avatars/123/abc.png?size=128"
+				held, _ := json.Marshal(imageRetry{ClientID: "client123", Activity: activity{
+					Application: "client123",
+					Name:        "Navidrome",
+					Type:        2,
+					Details:     "Test Song",
+					Assets: activityAssets{
+						LargeImage: "mp:external/art",
+						SmallImage: navidromeLogoURL,
+						SmallText:  "Navidrome",
+					},
+				}})
+				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+				pdk.PDKMock.On("GetConfig", clientIDKey).Return("client123", true)
+				pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"token123"}]`, true)
+				pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false).Maybe()
+				host.CacheMock.On("SetInt", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				host.CacheMock.On("GetString", avatarActivityKey("testuser")).Return(string(held), true, nil)
+				host.CacheMock.On("GetString", "discord.avatar.testuser").Return(avatarURL, true, nil)
+				host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+				host.CacheMock.On("SetString", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				host.CacheMock.On("Remove", avatarActivityKey("testuser")).Return(nil)
+				host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+					return string(req.Body) == `{"urls":["`+navidromeLogoURL+`","`+avatarURL+`"]}`
+				})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/logo"},{"external_asset_path":"external/avatar"}]`)}, nil)
+				host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"small_image":"mp:external/avatar"`)
+				})).Return(nil)
+
+				err := r.OnTextMessage(websocket.OnTextMessageRequest{
+					ConnectionID: "testuser",
+					Message:      `{"op":0,"t":"READY","s":1,"d":{"user":{"id":"123","avatar":"abc"}}}`,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				host.CacheMock.AssertCalled(GinkgoT(), "Remove", avatarActivityKey("testuser"))
+				host.WebSocketMock.AssertExpectations(GinkgoT())
+			})
+
 			It("returns error for invalid JSON", func() {
 				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
 				err := r.OnTextMessage(websocket.OnTextMessageRequest{
@@ -234,19 +292,25 @@ var _ = Describe("discordRPC", func() {
 		})
 	})
 
-	Describe("processImage", func() {
+	Describe("processImages", func() {
+		// processImage processes a single image URL.
+		processImage := func(imageURL string, ttl int64) (string, error) {
+			assets, errs := r.processImages([]imageAsset{{URL: imageURL, TTL: ttl}}, "client123", "token123")
+			return assets[0], errs[0]
+		}
+
 		BeforeEach(func() {
 			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
 		})
 
 		It("returns error for empty URL", func() {
-			_, err := r.processImage("", "client123", "token123", imageCacheTTL)
+			_, err := processImage("", imageCacheTTL)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("image URL is empty"))
 		})
 
 		It("returns mp: prefixed URL as-is", func() {
-			result, err := r.processImage("mp:external/abc123", "client123", "token123", imageCacheTTL)
+			result, err := processImage("mp:external/abc123", imageCacheTTL)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(result).To(Equal("mp:external/abc123"))
 		})
@@ -256,7 +320,7 @@ var _ = Describe("discordRPC", func() {
 				return strings.HasPrefix(key, "discord.image.")
 			})).Return("mp:cached/image", true, nil)
 
-			result, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+			result, err := processImage("https://example.com/art.jpg", imageCacheTTL)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(result).To(Equal("mp:cached/image"))
 		})
@@ -269,17 +333,46 @@ var _ = Describe("discordRPC", func() {
 
 			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/new-asset"}]`)}, nil)
 
-			result, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+			result, err := processImage("https://example.com/art.jpg", imageCacheTTL)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(result).To(Equal("mp:external/new-asset"))
 		})
 
+		It("caps the TTL at the expiry of a temporary upload", func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetString", rateLimitKey("client123")).Return("", false, nil)
+			expiresAt := time.Now().Unix() + 3600
+			host.CacheMock.On("GetString", "artwork.expiry."+hashKey("https://a.uguu.se/x.jpg")).Return(strconv.FormatInt(expiresAt, 10), true, nil)
+			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+			host.CacheMock.On("SetString", discordImageKey, "mp:external/new-asset", mock.MatchedBy(func(ttl int64) bool {
+				return ttl <= 3600 && ttl > 3500
+			})).Return(nil)
+			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/new-asset"}]`)}, nil)
+
+			_, err := processImage("https://a.uguu.se/x.jpg", imageCacheTTL)
+			Expect(err).ToNot(HaveOccurred())
+			host.CacheMock.AssertExpectations(GinkgoT())
+		})
+
+		It("doesn't cache assets of expired uploads", func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetString", rateLimitKey("client123")).Return("", false, nil)
+			host.CacheMock.On("GetString", "artwork.expiry."+hashKey("https://a.uguu.se/x.jpg")).Return("1000", true, nil)
+			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/new-asset"}]`)}, nil)
+
+			result, err := processImage("https://a.uguu.se/x.jpg", imageCacheTTL)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal("mp:external/new-asset"))
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", mock.Anything, mock.Anything, mock.Anything)
+		})
+
 		It("returns error on HTTP failure", func() {
 			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
 
 			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 500, Body: []byte(`error`)}, nil)
 
-			_, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+			_, err := processImage("https://example.com/art.jpg", imageCacheTTL)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("HTTP 500"))
 		})
@@ -289,7 +382,7 @@ var _ = Describe("discordRPC", func() {
 
 			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"not":"an-array"}`)}, nil)
 
-			_, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+			_, err := processImage("https://example.com/art.jpg", imageCacheTTL)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("failed to unmarshal"))
 		})
@@ -299,7 +392,7 @@ var _ = Describe("discordRPC", func() {
 
 			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[]`)}, nil)
 
-			_, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+			_, err := processImage("https://example.com/art.jpg", imageCacheTTL)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("no data returned"))
 		})
@@ -309,10 +402,80 @@ var _ = Describe("discordRPC", func() {
 
 			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":""}]`)}, nil)
 
-			_, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+			_, err := processImage("https://example.com/art.jpg", imageCacheTTL)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("empty external_asset_path"))
 		})
+
+		It("sends cache misses together and maps results by index", func() {
+			host.CacheMock.On("GetString", discordAssetCacheKey("client123", "https://example.com/cached.jpg")).Return("mp:cached/image", true, nil)
+			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+			host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
+			var sent host.HTTPRequest
+			host.HTTPMock.On("Send", externalAssetsReq).Run(func(args mock.Arguments) {
+				sent = args.Get(0).(host.HTTPRequest)
+			}).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/a"},{"external_asset_path":"external/b"}]`)}, nil).Once()
+
+			assets, errs := r.processImages([]imageAsset{
+				{URL: "https://example.com/a.jpg", TTL: imageCacheTTL},
+				{URL: "https://example.com/cached.jpg", TTL: imageCacheTTL},
+				{URL: "https://example.com/b.jpg", TTL: defaultImageCacheTTL},
+				{URL: "https://example.com/a.jpg", TTL: defaultImageCacheTTL},
+			}, "client123", "token123")
+			Expect(errs).To(Equal([]error{nil, nil, nil, nil}))
+			Expect(assets).To(Equal([]string{"mp:external/a", "mp:cached/image", "mp:external/b", "mp:external/a"}))
+			Expect(string(sent.Body)).To(Equal(`{"urls":["https://example.com/a.jpg","https://example.com/b.jpg"]}`))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", discordAssetCacheKey("client123", "https://example.com/a.jpg"), "mp:external/a", int64(imageCacheTTL))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", discordAssetCacheKey("client123", "https://example.com/b.jpg"), "mp:external/b", int64(defaultImageCacheTTL))
+		})
+
+		It("fails only the images without a result", func() {
+			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+			host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":""},{"external_asset_path":"external/b"}]`)}, nil)
+
+			assets, errs := r.processImages([]imageAsset{
+				{URL: "https://example.com/a.jpg", TTL: imageCacheTTL},
+				{URL: "https://example.com/b.jpg", TTL: imageCacheTTL},
+			}, "client123", "token123")
+			Expect(assets).To(Equal([]string{"", "mp:external/b"}))
+			Expect(errs[0]).To(MatchError(ContainSubstring("empty external_asset_path")))
+			Expect(errs[1]).ToNot(HaveOccurred())
+		})
+
+		It("sends at most two URLs per request and fails only the failed chunk", func() {
+			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+			host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return string(req.Body) == `{"urls":["https://example.com/a.jpg","https://example.com/b.jpg"]}`
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/a"},{"external_asset_path":"external/b"}]`)}, nil).Once()
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return string(req.Body) == `{"urls":["https://example.com/c.jpg"]}`
+			})).Return(&host.HTTPResponse{StatusCode: 400, Body: []byte(`{"message":"Invalid Form Body"}`)}, nil).Once()
+
+			assets, errs := r.processImages([]imageAsset{
+				{URL: "https://example.com/a.jpg", TTL: imageCacheTTL},
+				{URL: "https://example.com/b.jpg", TTL: defaultImageCacheTTL},
+				{URL: "https://example.com/c.jpg", TTL: defaultImageCacheTTL},
+			}, "client123", "token123")
+			Expect(assets).To(Equal([]string{"mp:external/a", "mp:external/b", ""}))
+			Expect(errs[0]).ToNot(HaveOccurred())
+			Expect(errs[1]).ToNot(HaveOccurred())
+			Expect(errs[2]).To(MatchError(ContainSubstring("HTTP 400")))
+			host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", 2)
+		})
+
+		It("doesn't send a request when everything is cached", func() {
+			host.CacheMock.On("GetString", discordImageKey).Return("mp:cached/image", true, nil)
+
+			assets, errs := r.processImages([]imageAsset{
+				{URL: "https://example.com/a.jpg", TTL: imageCacheTTL},
+				{URL: "", TTL: imageCacheTTL},
+			}, "client123", "token123")
+			Expect(assets[0]).To(Equal("mp:cached/image"))
+			Expect(errs[1]).To(MatchError("image URL is empty"))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
 	})
 
 	Describe("sendActivity", func() {
@@ -324,12 +487,15 @@ var _ = Describe("discordRPC", func() {
 			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
 			host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
 
-			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/art"}]`)}, nil)
+			// One request for the track art and the logo, which is also the small image
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return string(req.Body) == `{"urls":["https://example.com/art.jpg","`+navidromeLogoURL+`"]}`
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/art"},{"external_asset_path":"external/logo"}]`)}, nil).Once()
 
 			host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
 				return strings.Contains(msg, `"op":3`) &&
 					strings.Contains(msg, `"large_image":"mp:external/art"`) &&
-					strings.Contains(msg, `"small_image":"mp:external/art"`) &&
+					strings.Contains(msg, `"small_image":"mp:external/logo"`) &&
 					strings.Contains(msg, `"small_text":"Navidrome"`)
 			})).Return(nil)
 
@@ -350,13 +516,12 @@ var _ = Describe("discordRPC", func() {
 		})
 
 		It("falls back to default image and clears SmallImage", func() {
-			// Track art fails (HTTP error), default image succeeds
+			// Track art fails, default image succeeds
 			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
 			host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
 
-			// First call (track art) returns 500, second call (default) succeeds
-			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 500, Body: []byte(`error`)}, nil).Once()
-			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/logo"}]`)}, nil).Once()
+			// Discord has no asset for the track art, the default image succeeds
+			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":""},{"external_asset_path":"external/logo"}]`)}, nil).Once()
 
 			host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
 				return strings.Contains(msg, `"op":3`) &&
@@ -408,6 +573,30 @@ var _ = Describe("discordRPC", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("normalizes oversized fields before sending", func() {
+			host.CacheMock.On("GetString", discordImageKey).Return("mp:cached/art", true, nil)
+
+			host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+				return strings.Contains(msg, `"details":"`+strings.Repeat("a", maxTextLength-1)+ellipsis+`"`) &&
+					!strings.Contains(msg, `"details_url"`)
+			})).Return(nil)
+
+			err := r.sendActivity("client123", "testuser", "token123", activity{
+				Application: "client123",
+				Name:        "Navidrome",
+				Type:        2,
+				Details:     strings.Repeat("a", 300),
+				DetailsURL:  "https://open.spotify.com/search/" + strings.Repeat("a", 300),
+				State:       "Test Artist",
+				Assets: activityAssets{
+					LargeImage: "https://example.com/art.jpg",
+					LargeText:  "Test Album",
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			host.WebSocketMock.AssertExpectations(GinkgoT())
+		})
+
 		It("handles SmallImage processing failure gracefully", func() {
 			// LargeImage from cache (succeeds), SmallImage API fails
 			host.CacheMock.On("GetString", discordImageKey).Return("mp:cached/large", true, nil).Once()
@@ -435,6 +624,35 @@ var _ = Describe("discordRPC", func() {
 			})
 			Expect(err).ToNot(HaveOccurred())
 		})
+
+		It("keeps the track artwork when a separate small image fails", func() {
+			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+			host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return string(req.Body) == `{"urls":["https://example.com/art.jpg","`+navidromeLogoURL+`"]}`
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/art"},{"external_asset_path":"external/logo"}]`)}, nil).Once()
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return string(req.Body) == `{"urls":["https://example.com/badge.png"]}`
+			})).Return(&host.HTTPResponse{StatusCode: 500, Body: []byte(`error`)}, nil).Once()
+
+			host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+				return strings.Contains(msg, `"large_image":"mp:external/art"`) &&
+					!strings.Contains(msg, `"small_image"`)
+			})).Return(nil)
+
+			err := r.sendActivity("client123", "testuser", "token123", activity{
+				Application: "client123",
+				Name:        "Test Song",
+				Type:        2,
+				Assets: activityAssets{
+					LargeImage: "https://example.com/art.jpg",
+					SmallImage: "https://example.com/badge.png",
+					SmallText:  "FLAC 24/96",
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			host.WebSocketMock.AssertExpectations(GinkgoT())
+		})
 	})
 
 	Describe("clearActivity", func() {
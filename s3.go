@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// Configuration keys for the S3 image host
+const (
+	s3EndpointKey  = "s3endpoint"
+	s3BucketKey    = "s3bucket"
+	s3RegionKey    = "s3region"
+	s3AccessKeyKey = "s3accesskey"
+	s3SecretKeyKey = "s3secretkey"
+	s3PublicURLKey = "s3publicurl"
+	s3RetentionKey = "s3retention"
+)
+
+const (
+	defaultS3Region       = "us-east-1"
+	s3ObjectPrefix        = "discord-rich-presence/"
+	s3PresignExpiry int64 = 15 * 60 // Seconds the presigned upload URL is valid
+)
+
+// s3Config is an S3-compatible bucket (AWS, MinIO, R2, ...) reachable with path-style URLs.
+type s3Config struct {
+	Endpoint  string // e.g. https://minio.example.com
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	PublicURL string // Base URL objects are publicly read from
+	Retention int64  // Seconds objects are kept by the bucket's lifecycle rules, 0 when kept
+}
+
+// getS3Config reads the S3 image host settings. The public URL defaults to the bucket's
+// path-style URL.
+func getS3Config() (s3Config, error) {
+	get := func(key string) string {
+		v, _ := pdk.GetConfig(key)
+		return strings.TrimSpace(v)
+	}
+	cfg := s3Config{
+		Endpoint:  strings.TrimSuffix(get(s3EndpointKey), "/"),
+		Bucket:    get(s3BucketKey),
+		Region:    get(s3RegionKey),
+		AccessKey: get(s3AccessKeyKey),
+		SecretKey: get(s3SecretKeyKey),
+		PublicURL: strings.TrimSuffix(get(s3PublicURLKey), "/"),
+	}
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return cfg, errors.New("endpoint, bucket, access key and secret key are required")
+	}
+	if !isHTTPURL(cfg.Endpoint) {
+		return cfg, fmt.Errorf("S3 Endpoint %q is not an http(s) URL such as https://minio.example.com", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultS3Region
+	}
+	if cfg.PublicURL == "" {
+		cfg.PublicURL = cfg.Endpoint + "/" + cfg.Bucket
+	}
+	if hours, err := strconv.ParseInt(get(s3RetentionKey), 10, 64); err == nil && hours > 0 {
+		cfg.Retention = hours * 60 * 60
+	}
+	return cfg, nil
+}
+
+// s3Host uploads to an S3-compatible bucket with a presigned PUT. Objects are named after
+// their content, so re-uploading the same artwork overwrites the same object.
+type s3Host struct {
+	Config s3Config
+}
+
+func (s3Host) Name() string { return "s3" }
+
+func (h s3Host) Upload(data []byte, contentType string) (imageUpload, error) {
+	key := s3ObjectPrefix + contentHash(data) + imageExtension(contentType)
+
+	endpoint, err := url.Parse(h.Config.Endpoint)
+	if err != nil {
+		return imageUpload{}, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	objectPath := "/" + h.Config.Bucket + "/" + key
+	uploadURL := presignS3(h.Config, "PUT", endpoint, objectPath, s3PresignExpiry, time.Now())
+
+	resp, err := host.HTTPSend(host.HTTPRequest{
+		Method:  "PUT",
+		URL:     uploadURL,
+		Headers: map[string]string{"Content-Type": contentType},
+		Body:    data,
+	})
+	if err != nil {
+		return imageUpload{}, fmt.Errorf("S3 upload failed: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return imageUpload{}, fmt.Errorf("S3 upload failed: HTTP %d", resp.StatusCode)
+	}
+	return imageUpload{URL: h.Config.PublicURL + "/" + key, Retention: h.Config.Retention}, nil
+}
+
+// presignS3 returns a presigned URL for the request (AWS Signature Version 4, query string
+// authentication). Only the host header is signed and the payload is left unsigned, so the
+// URL can be used with any body.
+func presignS3(cfg s3Config, method string, endpoint *url.URL, objectPath string, expires int64, now time.Time) string {
+	now = now.UTC()
+	date := now.Format("20060102")
+	amzDate := now.Format("20060102T150405Z")
+	scope := date + "/" + cfg.Region + "/s3/aws4_request"
+
+	query := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    cfg.AccessKey + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       strconv.FormatInt(expires, 10),
+		"X-Amz-SignedHeaders": "host",
+	}
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	params := make([]string, len(names))
+	for i, name := range names {
+		params[i] = awsURIEncode(name, true) + "=" + awsURIEncode(query[name], true)
+	}
+	canonicalQuery := strings.Join(params, "&")
+	canonicalURI := awsURIEncode(endpoint.Path+objectPath, false)
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalURI,
+		canonicalQuery,
+		"host:" + endpoint.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+cfg.SecretKey), date)
+	key = hmacSHA256(key, cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	return endpoint.Scheme + "://" + endpoint.Host + canonicalURI + "?" + canonicalQuery + "&X-Amz-Signature=" + signature
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsURIEncode encodes s the way Signature Version 4 expects: every byte except unreserved
+// characters is percent-encoded, and slashes too unless they separate path segments.
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
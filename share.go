@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// imageHostShare serves artwork through Navidrome shares: the album is shared, and the
+// share page's public image endpoint is shown in Discord. Nothing leaves the server, and
+// only the share, not the Subsonic API, has to be publicly reachable.
+const imageHostShare = "Navidrome share"
+
+// payloadShareExpiry routes the scheduler callback that deletes an expired share. The
+// schedule ID is the share's record key.
+const payloadShareExpiry = "share-expiry"
+
+const (
+	shareKeyPrefix = "share."
+	// shareLifetime is how long a share is kept. Shares are replaced once they would expire
+	// within shareRefreshMargin, so Discord never shows artwork from a deleted share.
+	shareLifetime      int64 = 7 * 24 * 60 * 60
+	shareRefreshMargin int64 = 24 * 60 * 60
+	shareDescription         = "Discord Rich Presence artwork"
+)
+
+// ogImagePattern matches the og:image meta tag of a share page, which Navidrome points to
+// the share's public image endpoint.
+var ogImagePattern = regexp.MustCompile(`<meta[^>]+property="og:image"[^>]+content="([^"]+)"`)
+
+// shareRecord tracks a share created by the plugin, so it is reused while it lasts and
+// deleted when it expires. Records live in the KVStore, outside the resolution store, so
+// they are never evicted while the share exists.
+type shareRecord struct {
+	Username  string `json:"u"`
+	ShareID   string `json:"id"`
+	ImageURL  string `json:"img"`
+	ExpiresAt int64  `json:"e"` // Unix seconds
+}
+
+// shareRecordKey returns the KVStore key of the user's share of an album.
+func shareRecordKey(username, albumID string) string {
+	return shareKeyPrefix + hashKey(username+"\x00"+albumID)
+}
+
+// getImageViaShare returns the public image URL of a Navidrome share of the track's album,
+// creating the share when there is none that outlives the refresh margin. It returns ""
+// when the album can't be shared, so the caller falls back to the artwork URL.
+func getImageViaShare(username, trackID string) string {
+	song, err := getSong(username, trackID)
+	if err != nil || song.AlbumID == "" {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Cannot share artwork of track %s: no album", trackID))
+		return ""
+	}
+	key := shareRecordKey(username, song.AlbumID)
+	now := time.Now().Unix()
+
+	if cached, exists, err := host.CacheGetString(key); err == nil && exists {
+		return cached
+	}
+	if record, ok := loadShareRecord(key); ok {
+		if record.ExpiresAt-now > shareRefreshMargin {
+			_ = host.CacheSetString(key, record.ImageURL, record.ExpiresAt-now-shareRefreshMargin)
+			return record.ImageURL
+		}
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Share %s of album %s expires soon, sharing it again", record.ShareID, song.AlbumID))
+		_ = host.SchedulerCancelSchedule(key)
+		deleteShare(key, record)
+	}
+
+	cleanupExpiredShares()
+	record, err := createAlbumShare(username, song.AlbumID, now)
+	if err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to share album %s: %v", song.AlbumID, err))
+		return ""
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return ""
+	}
+	if err := host.KVStoreSet(key, data); err != nil {
+		// Without a record the share could never be cleaned up
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to record share %s: %v", record.ShareID, err))
+		deleteShare(key, record)
+		return ""
+	}
+	if _, err := host.SchedulerScheduleOneTime(int32(shareLifetime), payloadShareExpiry, key); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to schedule expiry of share %s: %v", record.ShareID, err))
+	}
+	_ = host.CacheSetString(key, record.ImageURL, shareLifetime-shareRefreshMargin)
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Shared album %s for artwork: %s", song.AlbumID, record.ImageURL))
+	return record.ImageURL
+}
+
+// createAlbumShare shares the album on behalf of username and finds the share's public image.
+// The share is deleted again if its image can't be used.
+func createAlbumShare(username, albumID string, now int64) (shareRecord, error) {
+	record := shareRecord{Username: username, ExpiresAt: now + shareLifetime}
+	body, err := host.SubsonicAPICall(fmt.Sprintf("/createShare?u=%s&id=%s&description=%s&expires=%d",
+		url.QueryEscape(username), url.QueryEscape(albumID), url.QueryEscape(shareDescription), record.ExpiresAt*1000))
+	if err != nil {
+		return record, fmt.Errorf("createShare failed: %w", err)
+	}
+	resp, err := parseSubsonicResponse(body)
+	if err != nil {
+		return record, err
+	}
+	if resp.Response.Shares == nil || len(resp.Response.Shares.Share) == 0 || resp.Response.Shares.Share[0].URL == "" {
+		return record, fmt.Errorf("no share returned")
+	}
+	share := resp.Response.Shares.Share[0]
+	record.ShareID = share.ID
+
+	record.ImageURL, err = shareImageURL(share.URL)
+	if err == nil && isPrivateURL(record.ImageURL) {
+		err = fmt.Errorf("share image %s is not public, check ND_BASEURL", record.ImageURL)
+	}
+	if err != nil {
+		deleteShare("", record)
+		return record, err
+	}
+	return record, nil
+}
+
+// shareImageURL reads the public image endpoint from the share page's og:image tag.
+func shareImageURL(shareURL string) (string, error) {
+	resp, err := host.HTTPSend(host.HTTPRequest{Method: "GET", URL: shareURL})
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch share page: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("failed to fetch share page: HTTP %d", resp.StatusCode)
+	}
+	match := ogImagePattern.FindSubmatch(resp.Body)
+	if match == nil {
+		return "", fmt.Errorf("share page has no image")
+	}
+	imageURL := html.UnescapeString(string(match[1]))
+	if strings.HasPrefix(imageURL, "/") {
+		// Relative to the share page
+		base, err := url.Parse(shareURL)
+		if err != nil {
+			return "", err
+		}
+		ref, _ := url.Parse(imageURL)
+		imageURL = base.ResolveReference(ref).String()
+	}
+	return imageURL, nil
+}
+
+// loadShareRecord reads the share record stored under key.
+func loadShareRecord(key string) (shareRecord, bool) {
+	var record shareRecord
+	data, exists, err := host.KVStoreGet(key)
+	if err != nil || !exists {
+		return record, false
+	}
+	if err := json.Unmarshal(data, &record); err != nil || record.ShareID == "" {
+		_ = host.KVStoreDelete(key)
+		return record, false
+	}
+	return record, true
+}
+
+// deleteShare deletes the share from Navidrome, then its record under key, if any. The
+// record is kept when Navidrome can't be reached, so a later sweep tries again.
+func deleteShare(key string, record shareRecord) {
+	body, err := host.SubsonicAPICall(fmt.Sprintf("/deleteShare?u=%s&id=%s", url.QueryEscape(record.Username), url.QueryEscape(record.ShareID)))
+	if err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to delete share %s: %v", record.ShareID, err))
+		return
+	}
+	if _, err := parseSubsonicResponse(body); err != nil {
+		// Usually deleted in Navidrome already, retrying wouldn't help
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Share %s was not deleted: %v", record.ShareID, err))
+	}
+	if key != "" {
+		_ = host.CacheRemove(key)
+		_ = host.KVStoreDelete(key)
+	}
+}
+
+// handleShareExpiryCallback deletes the share whose record key is the schedule ID.
+func handleShareExpiryCallback(key string) {
+	record, ok := loadShareRecord(key)
+	if !ok {
+		return
+	}
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Deleting expired share %s", record.ShareID))
+	deleteShare(key, record)
+}
+
+// cleanupExpiredShares deletes the shares whose records have expired. Scheduled expiries
+// don't survive a restart, so this sweep catches the ones that were missed.
+func cleanupExpiredShares() {
+	keys, err := host.KVStoreList(shareKeyPrefix)
+	if err != nil {
+		return
+	}
+	now := time.Now().Unix()
+	for _, key := range keys {
+		if record, ok := loadShareRecord(key); ok && record.ExpiresAt <= now {
+			pdk.Log(pdk.LogInfo, fmt.Sprintf("Deleting expired share %s", record.ShareID))
+			deleteShare(key, record)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Navidrome shares", func() {
+	key := shareRecordKey("testuser", "album1")
+	sharePage := `<html><head><meta property="og:title" content="OK Computer">` +
+		`<meta property="og:image" content="https://music.example.com/share/img/eyJ0?size=600&amp;square=true"></head></html>`
+
+	// recordJSON returns a stored share record expiring at expiresAt.
+	recordJSON := func(shareID string, expiresAt int64) []byte {
+		data, _ := json.Marshal(shareRecord{Username: "testuser", ShareID: shareID, ImageURL: "https://music.example.com/share/img/" + shareID, ExpiresAt: expiresAt})
+		return data
+	}
+
+	// withRecords replaces the KVStore mocks, so Get returns the given records.
+	withRecords := func(records map[string][]byte) {
+		host.KVStoreMock.ExpectedCalls = nil
+		var keys []string
+		for k, data := range records {
+			keys = append(keys, k)
+			host.KVStoreMock.On("Get", k).Return(data, true, nil)
+		}
+		host.KVStoreMock.On("Get", mock.Anything).Return([]byte(nil), false, nil).Maybe()
+		host.KVStoreMock.On("Set", mock.Anything, mock.Anything).Return(nil).Maybe()
+		host.KVStoreMock.On("Delete", mock.Anything).Return(nil).Maybe()
+		host.KVStoreMock.On("List", shareKeyPrefix).Return(keys, nil).Maybe()
+	}
+
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.SubsonicAPIMock.ExpectedCalls = nil
+		host.SubsonicAPIMock.Calls = nil
+		host.HTTPMock.ExpectedCalls = nil
+		host.HTTPMock.Calls = nil
+		host.SchedulerMock.ExpectedCalls = nil
+		host.SchedulerMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		host.KVStoreMock.On("List", shareKeyPrefix).Return([]string{}, nil).Maybe()
+		host.CacheMock.On("GetString", "subsonic.song.testuser.track1").Return(`{"id":"track1","albumId":"album1"}`, true, nil)
+		host.CacheMock.On("GetString", key).Return("", false, nil).Maybe()
+		host.CacheMock.On("SetString", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+		host.CacheMock.On("Remove", mock.Anything).Return(nil).Maybe()
+		host.SubsonicAPIMock.On("Call", mock.MatchedBy(func(uri string) bool {
+			return strings.HasPrefix(uri, "/deleteShare?")
+		})).Return(`{"subsonic-response":{"status":"ok"}}`, nil).Maybe()
+	})
+
+	Describe("getImageViaShare", func() {
+		It("shares the album and records the share", func() {
+			host.SubsonicAPIMock.On("Call", mock.MatchedBy(func(uri string) bool {
+				return strings.HasPrefix(uri, "/createShare?u=testuser&id=album1&")
+			})).Return(`{"subsonic-response":{"status":"ok","shares":{"share":[{"id":"abc","url":"https://music.example.com/share/abc"}]}}}`, nil)
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return req.URL == "https://music.example.com/share/abc"
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(sharePage)}, nil)
+			host.SchedulerMock.On("ScheduleOneTime", int32(shareLifetime), payloadShareExpiry, key).Return(key, nil)
+
+			imageURL := getImageViaShare("testuser", "track1")
+			Expect(imageURL).To(Equal("https://music.example.com/share/img/eyJ0?size=600&square=true"))
+			host.KVStoreMock.AssertCalled(GinkgoT(), "Set", key, mock.MatchedBy(func(data []byte) bool {
+				var record shareRecord
+				return json.Unmarshal(data, &record) == nil && record.ShareID == "abc" && record.Username == "testuser"
+			}))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", key, imageURL, shareLifetime-shareRefreshMargin)
+			host.SchedulerMock.AssertExpectations(GinkgoT())
+		})
+
+		It("reuses a recorded share", func() {
+			withRecords(map[string][]byte{key: recordJSON("abc", time.Now().Unix()+3*24*60*60)})
+
+			Expect(getImageViaShare("testuser", "track1")).To(Equal("https://music.example.com/share/img/abc"))
+			host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "Call", mock.Anything)
+		})
+
+		It("replaces a share that expires soon", func() {
+			withRecords(map[string][]byte{key: recordJSON("old", time.Now().Unix()+60*60)})
+			host.SchedulerMock.On("CancelSchedule", key).Return(nil)
+			host.SchedulerMock.On("ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything).Return(key, nil)
+			host.SubsonicAPIMock.On("Call", mock.MatchedBy(func(uri string) bool {
+				return strings.HasPrefix(uri, "/createShare?")
+			})).Return(`{"subsonic-response":{"status":"ok","shares":{"share":[{"id":"new","url":"https://music.example.com/share/new"}]}}}`, nil)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(sharePage)}, nil)
+
+			Expect(getImageViaShare("testuser", "track1")).ToNot(BeEmpty())
+			host.SubsonicAPIMock.AssertCalled(GinkgoT(), "Call", "/deleteShare?u=testuser&id=old")
+			host.SchedulerMock.AssertCalled(GinkgoT(), "CancelSchedule", key)
+		})
+
+		It("deletes the share when its image is private", func() {
+			host.SubsonicAPIMock.On("Call", mock.MatchedBy(func(uri string) bool {
+				return strings.HasPrefix(uri, "/createShare?")
+			})).Return(`{"subsonic-response":{"status":"ok","shares":{"share":[{"id":"abc","url":"http://192.168.1.10:4533/share/abc"}]}}}`, nil)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`<meta property="og:image" content="/share/img/eyJ0">`)}, nil)
+
+			Expect(getImageViaShare("testuser", "track1")).To(BeEmpty())
+			host.SubsonicAPIMock.AssertCalled(GinkgoT(), "Call", "/deleteShare?u=testuser&id=abc")
+			host.KVStoreMock.AssertNotCalled(GinkgoT(), "Set", key, mock.Anything)
+		})
+
+		It("returns empty when sharing is disabled", func() {
+			host.SubsonicAPIMock.On("Call", mock.Anything).Return(`{"subsonic-response":{"status":"failed","error":{"code":0,"message":"sharing disabled"}}}`, nil)
+
+			Expect(getImageViaShare("testuser", "track1")).To(BeEmpty())
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+	})
+
+	Describe("shareImageURL", func() {
+		It("resolves relative images against the share page", func() {
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`<meta property="og:image" content="/music/share/img/eyJ0?size=600">`)}, nil)
+			Expect(shareImageURL("https://example.com/music/share/abc")).To(Equal("https://example.com/music/share/img/eyJ0?size=600"))
+		})
+
+		It("returns an error when the page has no image", func() {
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`<html></html>`)}, nil)
+			_, err := shareImageURL("https://example.com/share/abc")
+			Expect(err).To(MatchError("share page has no image"))
+		})
+	})
+
+	Describe("cleanup", func() {
+		It("deletes the share when its expiry callback fires", func() {
+			withRecords(map[string][]byte{key: recordJSON("abc", time.Now().Unix())})
+
+			handleShareExpiryCallback(key)
+			host.SubsonicAPIMock.AssertCalled(GinkgoT(), "Call", "/deleteShare?u=testuser&id=abc")
+			host.KVStoreMock.AssertCalled(GinkgoT(), "Delete", key)
+		})
+
+		It("sweeps only expired shares", func() {
+			other := shareRecordKey("testuser", "album2")
+			withRecords(map[string][]byte{
+				key:   recordJSON("expired", time.Now().Unix()-60),
+				other: recordJSON("live", time.Now().Unix()+60),
+			})
+
+			cleanupExpiredShares()
+			host.SubsonicAPIMock.AssertCalled(GinkgoT(), "Call", "/deleteShare?u=testuser&id=expired")
+			host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "Call", "/deleteShare?u=testuser&id=live")
+			host.KVStoreMock.AssertNotCalled(GinkgoT(), "Delete", other)
+		})
+
+		It("keeps the record when Navidrome can't be reached", func() {
+			host.SubsonicAPIMock.ExpectedCalls = nil
+			host.SubsonicAPIMock.On("Call", mock.Anything).Return("", errors.New("unavailable"))
+			withRecords(map[string][]byte{key: recordJSON("abc", time.Now().Unix())})
+
+			handleShareExpiryCallback(key)
+			host.KVStoreMock.AssertNotCalled(GinkgoT(), "Delete", key)
+		})
+	})
+})
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+)
+
+//go:generate go run ./badges/generate.go
+
+// Configuration keys for the small image
+const (
+	smallImageKey    = "smallimage"
+	smallImageURLKey = "smallimageurl"
+	badgeBaseURLKey  = "badgebaseurl"
+)
+
+// Small image options
+const (
+	smallImageLogo   = "Navidrome logo"
+	smallImageCustom = "Custom URL"
+	smallImageAvatar = "Discord avatar"
+	smallImageArtist = "Artist image"
+	smallImageFormat = "Audio format"
+)
+
+const (
+	discordAvatarTTL   int64 = 7 * 24 * 60 * 60 // Refreshed whenever the gateway sends READY
+	avatarActivityTTL  int64 = 60               // READY follows a new connection within seconds
+	artistImageTTL     int64 = 24 * 60 * 60
+	artistImageMissTTL int64 = 4 * 60 * 60
+)
+
+// getSmallImage returns the small image configured for the track, and its hover text, or ""
+// to keep the default text. It falls back to the Navidrome logo when the configured image
+// isn't available.
+func getSmallImage(username string, track scrobbler.TrackInfo) (imageURL, text string) {
+	mode, _ := pdk.GetConfig(smallImageKey)
+	switch mode {
+	case smallImageCustom:
+		if customURL, _ := pdk.GetConfig(smallImageURLKey); isHTTPURL(customURL) {
+			return strings.TrimSpace(customURL), ""
+		}
+		pdk.Log(pdk.LogWarn, "Small image URL is not a valid http(s) URL, using the Navidrome logo")
+	case smallImageAvatar:
+		if avatarURL := getDiscordAvatar(username); avatarURL != "" {
+			return avatarURL, ""
+		}
+	case smallImageArtist:
+		if artist, ok := trackArtist(track); ok {
+			if imageURL := getArtistImage(username, artist.ID, int64(track.Duration)); imageURL != "" {
+				return imageURL, artist.Name
+			}
+		}
+	case smallImageFormat:
+		if text, badgeURL := getAudioFormat(username, track.ID); badgeURL != "" {
+			return badgeURL, text
+		}
+	}
+	return navidromeLogoURL, ""
+}
+
+// isHTTPURL reports whether value is an absolute http(s) URL.
+func isHTTPURL(value string) bool {
+	u, err := url.Parse(strings.TrimSpace(value))
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// ============================================================================
+// Discord avatar
+// ============================================================================
+
+func discordAvatarKey(username string) string {
+	return fmt.Sprintf("discord.avatar.%s", username)
+}
+
+// discordAvatarURL returns the CDN URL of a Discord user's avatar. Users without one get
+// their default avatar, picked from their ID.
+func discordAvatarURL(userID, avatarHash string) string {
+	if avatarHash != "" {
+		return fmt.Sprintf("https://cdn.discordapp.com/avatars/%s/%s.png?size=128", userID, avatarHash)
+	}
+	id, _ := strconv.ParseUint(userID, 10, 64)
+	return fmt.Sprintf("https://cdn.discordapp.com/embed/avatars/%d.png", (id>>22)%6)
+}
+
+// cacheDiscordAvatar remembers the avatar of the user the gateway connection is logged in
+// as, from the READY event.
+func cacheDiscordAvatar(username, message string) {
+	var ready struct {
+		D struct {
+			User struct {
+				ID     string `json:"id"`
+				Avatar string `json:"avatar"`
+			} `json:"user"`
+		} `json:"d"`
+	}
+	if err := json.Unmarshal([]byte(message), &ready); err != nil || ready.D.User.ID == "" {
+		return
+	}
+	_ = host.CacheSetString(discordAvatarKey(username), discordAvatarURL(ready.D.User.ID, ready.D.User.Avatar), discordAvatarTTL)
+}
+
+// getDiscordAvatar returns the avatar URL cached from the user's last READY event.
+func getDiscordAvatar(username string) string {
+	avatarURL, exists, err := host.CacheGetString(discordAvatarKey(username))
+	if err != nil || !exists {
+		return ""
+	}
+	return avatarURL
+}
+
+func avatarActivityKey(username string) string {
+	return fmt.Sprintf("discord.activity.avatar.%s", username)
+}
+
+// awaitDiscordAvatar holds on to an activity sent with the logo in place of the user's
+// avatar, which isn't known until the READY event of a new connection. sendAvatarActivity
+// sends it again once READY arrives.
+func awaitDiscordAvatar(clientID, username string, data activity) {
+	if mode, _ := pdk.GetConfig(smallImageKey); mode != smallImageAvatar || getDiscordAvatar(username) != "" {
+		return
+	}
+	payload, err := json.Marshal(imageRetry{ClientID: clientID, Activity: data})
+	if err != nil {
+		return
+	}
+	_ = host.CacheSetString(avatarActivityKey(username), string(payload), avatarActivityTTL)
+}
+
+// sendAvatarActivity sends the activity held by awaitDiscordAvatar again, with the avatar
+// cached from READY as its small image.
+func (r *discordRPC) sendAvatarActivity(username string) error {
+	cached, exists, err := host.CacheGetString(avatarActivityKey(username))
+	if err != nil || !exists {
+		return nil
+	}
+	_ = host.CacheRemove(avatarActivityKey(username))
+	avatarURL := getDiscordAvatar(username)
+	if avatarURL == "" {
+		return nil
+	}
+	var held imageRetry
+	if err := json.Unmarshal([]byte(cached), &held); err != nil {
+		return fmt.Errorf("failed to parse held activity: %w", err)
+	}
+
+	_, users, err := getConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get config: %w", err)
+	}
+	token, ok := users[username]
+	if !ok {
+		return nil
+	}
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Discord avatar of user %s is known, updating the small image", username))
+	held.Activity.Assets.SmallImage = avatarURL
+	return r.sendActivity(held.ClientID, username, token, held.Activity)
+}
+
+// ============================================================================
+// Artist image
+// ============================================================================
+
+// trackArtist returns the first track artist known to Navidrome, or else the first album artist.
+func trackArtist(track scrobbler.TrackInfo) (scrobbler.ArtistRef, bool) {
+	for _, artists := range [][]scrobbler.ArtistRef{track.Artists, track.AlbumArtists} {
+		for _, artist := range artists {
+			if artist.ID != "" {
+				return artist, true
+			}
+		}
+	}
+	return scrobbler.ArtistRef{}, false
+}
+
+// getArtistImage returns the artist's image, preferring the medium size, for a track of
+// duration seconds. Like track artwork, it is uploaded to the image host when one is
+// configured, or to privateFallbackHost when its URL is private, as Discord can't fetch it.
+func getArtistImage(username, artistID string, duration int64) string {
+	imageURL := getArtistInfoImage(username, artistID)
+	if imageURL == "" {
+		return ""
+	}
+	if imageHost := getImageHost(); imageHost != nil {
+		return uploadCoverArt(imageHost, username, "ar-"+artistID, artistArtworkKey(imageHost, artistID), duration)
+	}
+	if isPrivateURL(imageURL) {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Artist image %s is private, uploading to %s", imageURL, privateFallbackHost.Name()))
+		return uploadCoverArt(privateFallbackHost, username, "ar-"+artistID, artistArtworkKey(privateFallbackHost, artistID), duration)
+	}
+	return imageURL
+}
+
+// artistArtworkKey caches the hash of an artist's image, so its upload is reused without
+// fetching the image again.
+func artistArtworkKey(imageHost ImageHost, artistID string) string {
+	return fmt.Sprintf("%s.artwork.artist.%s", imageHost.Name(), artistID)
+}
+
+// getArtistInfoImage returns the URL of the artist's image from getArtistInfo2, preferring
+// the medium size. Misses are cached too.
+func getArtistInfoImage(username, artistID string) string {
+	cacheKey := fmt.Sprintf("subsonic.artistimage.%s", artistID)
+	if cached, exists, err := host.CacheGetString(cacheKey); err == nil && exists {
+		return cached
+	}
+
+	imageURL := ""
+	body, err := host.SubsonicAPICall(fmt.Sprintf("/getArtistInfo2?u=%s&id=%s", url.QueryEscape(username), url.QueryEscape(artistID)))
+	if err != nil {
+		// Not cached, Navidrome may just be busy
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to fetch artist info for %s: %v", artistID, err))
+		return ""
+	}
+	if resp, err := parseSubsonicResponse(body); err == nil && resp.Response.ArtistInfo2 != nil {
+		info := resp.Response.ArtistInfo2
+		for _, candidate := range []string{info.MediumImageURL, info.LargeImageURL, info.SmallImageURL} {
+			if candidate != "" {
+				imageURL = candidate
+				break
+			}
+		}
+	}
+
+	ttl := artistImageTTL
+	if imageURL == "" {
+		ttl = artistImageMissTTL
+	}
+	_ = host.CacheSetString(cacheKey, imageURL, ttl)
+	return imageURL
+}
+
+// ============================================================================
+// Audio format badge
+// ============================================================================
+
+// audioCodec describes a file suffix: the label shown, and the badge image without its extension.
+type audioCodec struct {
+	label    string
+	badge    string
+	lossless bool
+}
+
+// audioCodecs maps file suffixes to codecs. m4a is AAC unless it has a bit depth, which
+// only ALAC files report.
+var audioCodecs = map[string]audioCodec{
+	"flac": {"FLAC", "flac", true},
+	"alac": {"ALAC", "alac", true},
+	"wav":  {"WAV", "wav", true},
+	"aif":  {"AIFF", "aiff", true},
+	"aiff": {"AIFF", "aiff", true},
+	"ape":  {"APE", "ape", true},
+	"wv":   {"WV", "wv", true},
+	"dsf":  {"DSD", "dsd", true},
+	"dff":  {"DSD", "dsd", true},
+	"mp3":  {"MP3", "mp3", false},
+	"aac":  {"AAC", "aac", false},
+	"m4a":  {"AAC", "aac", false},
+	"ogg":  {"OGG", "ogg", false},
+	"oga":  {"OGG", "ogg", false},
+	"opus": {"OPUS", "opus", false},
+	"wma":  {"WMA", "wma", false},
+}
+
+// getAudioFormat returns the format badge text and image of the user's current track.
+func getAudioFormat(username, trackID string) (text, badgeURL string) {
+	song, err := getSong(username, trackID)
+	if err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to get audio format for track %s: %v", trackID, err))
+		return "", ""
+	}
+	return audioFormatBadge(song)
+}
+
+// audioFormatBadge renders the song's format, e.g. "FLAC 24/96", "DSD64" or "MP3 320", and
+// picks its badge. Hi-res lossless files (over 16 bits or 48 kHz) get the gold badge.
+// Unknown formats are labeled by their suffix and use the Navidrome logo.
+func audioFormatBadge(song *subsonicSong) (text, badgeURL string) {
+	if song == nil || song.Suffix == "" {
+		return "", ""
+	}
+	suffix := strings.ToLower(song.Suffix)
+	codec, known := audioCodecs[suffix]
+	if suffix == "m4a" && song.BitDepth > 0 {
+		codec = audioCodecs["alac"]
+	}
+	if !known {
+		codec = audioCodec{label: strings.ToUpper(suffix)}
+	}
+
+	switch {
+	case codec.badge == "dsd":
+		text = codec.label
+		if song.SamplingRate > 0 {
+			text = fmt.Sprintf("DSD%d", song.SamplingRate/44100)
+		}
+	case codec.lossless:
+		text = codec.label
+		if song.BitDepth > 0 && song.SamplingRate > 0 {
+			text = fmt.Sprintf("%s %d/%s", codec.label, song.BitDepth, formatKHz(song.SamplingRate))
+		} else if song.BitDepth > 0 {
+			text = fmt.Sprintf("%s %d-bit", codec.label, song.BitDepth)
+		}
+		if song.BitDepth > 16 || song.SamplingRate > 48000 {
+			codec.badge += "-hires"
+		}
+	default:
+		text = codec.label
+		if song.BitRate > 0 {
+			text = fmt.Sprintf("%s %d", codec.label, song.BitRate)
+		}
+	}
+
+	if codec.badge == "" {
+		return text, navidromeLogoURL
+	}
+	return text, badgeBaseURL() + codec.badge + ".png"
+}
+
+// badgeBaseURL returns the configured location of the format badges, or the upstream
+// repository's when none is set.
+func badgeBaseURL() string {
+	baseURL, _ := pdk.GetConfig(badgeBaseURLKey)
+	if strings.TrimSpace(baseURL) == "" {
+		return defaultBadgeBaseURL
+	}
+	if !isHTTPURL(baseURL) {
+		pdk.Log(pdk.LogWarn, "Badge base URL is not a valid http(s) URL, using the default badges")
+		return defaultBadgeBaseURL
+	}
+	return strings.TrimSuffix(strings.TrimSpace(baseURL), "/") + "/"
+}
+
+// formatKHz renders a sampling rate in kHz, e.g. 44100 as "44.1" and 96000 as "96".
+func formatKHz(hz int) string {
+	return strconv.FormatFloat(float64(hz)/1000, 'f', -1, 64)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Small image", func() {
+	track := scrobbler.TrackInfo{
+		ID:           "track1",
+		Artists:      []scrobbler.ArtistRef{{Name: "Guest"}, {ID: "artist1", Name: "Radiohead"}},
+		AlbumArtists: []scrobbler.ArtistRef{{ID: "artist2", Name: "Various Artists"}},
+	}
+
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.SubsonicAPIMock.ExpectedCalls = nil
+		host.SubsonicAPIMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		host.CacheMock.On("SetString", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	})
+
+	Describe("audioFormatBadge", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("GetConfig", badgeBaseURLKey).Return("", false).Maybe()
+		})
+
+		DescribeTable("renders the format and picks the badge",
+			func(song *subsonicSong, expectedText, expectedBadge string) {
+				text, badgeURL := audioFormatBadge(song)
+				Expect(text).To(Equal(expectedText))
+				if expectedBadge == "" {
+					Expect(badgeURL).To(BeEmpty())
+				} else {
+					Expect(badgeURL).To(Equal(defaultBadgeBaseURL + expectedBadge))
+				}
+			},
+			Entry("no song", nil, "", ""),
+			Entry("no suffix", &subsonicSong{}, "", ""),
+			Entry("CD quality FLAC", &subsonicSong{Suffix: "flac", BitDepth: 16, SamplingRate: 44100}, "FLAC 16/44.1", "flac.png"),
+			Entry("hi-res FLAC", &subsonicSong{Suffix: "FLAC", BitDepth: 24, SamplingRate: 96000}, "FLAC 24/96", "flac-hires.png"),
+			Entry("FLAC without a sampling rate", &subsonicSong{Suffix: "flac", BitDepth: 24}, "FLAC 24-bit", "flac-hires.png"),
+			Entry("FLAC without details", &subsonicSong{Suffix: "flac"}, "FLAC", "flac.png"),
+			Entry("ALAC in m4a", &subsonicSong{Suffix: "m4a", BitDepth: 16, SamplingRate: 48000}, "ALAC 16/48", "alac.png"),
+			Entry("AAC in m4a", &subsonicSong{Suffix: "m4a", BitRate: 256}, "AAC 256", "aac.png"),
+			Entry("AIFF", &subsonicSong{Suffix: "aif", BitDepth: 16, SamplingRate: 88200}, "AIFF 16/88.2", "aiff-hires.png"),
+			Entry("DSD64", &subsonicSong{Suffix: "dsf", SamplingRate: 2822400}, "DSD64", "dsd.png"),
+			Entry("MP3", &subsonicSong{Suffix: "mp3", BitRate: 320}, "MP3 320", "mp3.png"),
+			Entry("Opus without a bitrate", &subsonicSong{Suffix: "opus"}, "OPUS", "opus.png"),
+		)
+
+		It("labels unknown formats by suffix and uses the Navidrome logo", func() {
+			text, badgeURL := audioFormatBadge(&subsonicSong{Suffix: "mpc", BitRate: 180})
+			Expect(text).To(Equal("MPC 180"))
+			Expect(badgeURL).To(Equal(navidromeLogoURL))
+		})
+	})
+
+	Describe("discordAvatarURL", func() {
+		It("returns the user's avatar", func() {
+			Expect(discordAvatarURL("80351110224678912", "8342729096ea3675442027381ff50dfe")).
+				To(Equal("https://cdn.discordapp.com/avatars/80351110224678912/8342729096ea3675442027381ff50dfe.png?size=128"))
+		})
+
+		It("returns the default avatar for users without one", func() {
+			Expect(discordAvatarURL("80351110224678912", "")).To(Equal("https://cdn.discordapp.com/embed/avatars/5.png"))
+		})
+	})
+
+	Describe("cacheDiscordAvatar", func() {
+		It("caches the avatar from the READY event", func() {
+			cacheDiscordAvatar("testuser", `{"op":0,"t":"READY","s":1,"d":{"user":{"id":"123","avatar":"abc"}}}`)
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.avatar.testuser", "https://cdn.discordapp.com/avatars/123/abc.png?size=128", discordAvatarTTL)
+		})
+
+		It("ignores events without a user", func() {
+			cacheDiscordAvatar("testuser", `{"op":0,"t":"READY","s":1,"d":{}}`)
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", mock.Anything, mock.Anything, mock.Anything)
+		})
+	})
+
+	Describe("awaitDiscordAvatar", func() {
+		data := activity{Details: "Test Song", Assets: activityAssets{SmallImage: navidromeLogoURL}}
+
+		It("holds the activity until READY reports the avatar", func() {
+			pdk.PDKMock.On("GetConfig", smallImageKey).Return(smallImageAvatar, true)
+			host.CacheMock.On("GetString", "discord.avatar.testuser").Return("", false, nil)
+
+			awaitDiscordAvatar("client123", "testuser", data)
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", avatarActivityKey("testuser"), mock.MatchedBy(func(v string) bool {
+				return strings.Contains(v, `"clientId":"client123"`) && strings.Contains(v, `"details":"Test Song"`)
+			}), avatarActivityTTL)
+		})
+
+		It("doesn't hold the activity when the avatar is known", func() {
+			pdk.PDKMock.On("GetConfig", smallImageKey).Return(smallImageAvatar, true)
+			host.CacheMock.On("GetString", "discord.avatar.testuser").Return("https://cdn.discordapp.com/avatars/123/abc.png?size=128", true, nil)
+
+			awaitDiscordAvatar("client123", "testuser", data)
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("doesn't hold the activity for other small images", func() {
+			pdk.PDKMock.On("GetConfig", smallImageKey).Return(smallImageLogo, true)
+
+			awaitDiscordAvatar("client123", "testuser", data)
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", mock.Anything, mock.Anything, mock.Anything)
+		})
+	})
+
+	Describe("getArtistImage", func() {
+		fakeArt := []byte("fake-artist-image")
+		uguuUpload := mock.MatchedBy(func(req host.HTTPRequest) bool { return req.URL == "https://uguu.se/upload" })
+
+		BeforeEach(func() {
+			host.HTTPMock.ExpectedCalls = nil
+			host.HTTPMock.Calls = nil
+			pdk.PDKMock.On("GetConfig", uguuEnabledKey).Return("", false).Maybe()
+			pdk.PDKMock.On("GetConfig", artworkSizeKey).Return("", false).Maybe()
+			pdk.PDKMock.On("GetConfig", artworkQualityKey).Return("", false).Maybe()
+			host.CacheMock.On("GetString", artworkExpiryArg).Return("", false, nil).Maybe()
+		})
+
+		It("prefers the medium image", func() {
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return("", false)
+			host.CacheMock.On("GetString", "subsonic.artistimage.artist1").Return("", false, nil)
+			host.SubsonicAPIMock.On("Call", "/getArtistInfo2?u=testuser&id=artist1").Return(`{"subsonic-response":{"status":"ok","artistInfo2":{"smallImageUrl":"https://img.example.com/s.jpg","mediumImageUrl":"https://img.example.com/m.jpg","largeImageUrl":"https://img.example.com/l.jpg"}}}`, nil)
+
+			Expect(getArtistImage("testuser", "artist1", 200)).To(Equal("https://img.example.com/m.jpg"))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "subsonic.artistimage.artist1", "https://img.example.com/m.jpg", artistImageTTL)
+		})
+
+		It("uploads private images to the fallback host", func() {
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return("", false)
+			host.CacheMock.On("GetString", "subsonic.artistimage.artist1").Return("http://localhost:4533/share/img/x", true, nil)
+			host.CacheMock.On("GetString", mock.Anything).Return("", false, nil)
+			host.SubsonicAPIMock.On("CallRaw", "/getCoverArt?u=testuser&id=ar-artist1&size=300").Return("image/jpeg", fakeArt, nil)
+			host.HTTPMock.On("Send", uguuUpload).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"success":true,"files":[{"url":"https://a.uguu.se/artist.jpg"}]}`)}, nil)
+
+			Expect(getArtistImage("testuser", "artist1", 200)).To(Equal("https://a.uguu.se/artist.jpg"))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "uguu.artwork.artist.artist1", contentHash(fakeArt), albumArtworkTTL)
+		})
+
+		It("uploads to the configured image host", func() {
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return(imageHostUguu, true)
+			host.CacheMock.On("GetString", "subsonic.artistimage.artist1").Return("https://img.example.com/m.jpg", true, nil)
+			host.CacheMock.On("GetString", mock.Anything).Return("", false, nil)
+			host.SubsonicAPIMock.On("CallRaw", "/getCoverArt?u=testuser&id=ar-artist1&size=300").Return("image/jpeg", fakeArt, nil)
+			host.HTTPMock.On("Send", uguuUpload).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"success":true,"files":[{"url":"https://a.uguu.se/artist.jpg"}]}`)}, nil)
+
+			Expect(getArtistImage("testuser", "artist1", 200)).To(Equal("https://a.uguu.se/artist.jpg"))
+		})
+
+		It("reuses the upload of the artist's image", func() {
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return(imageHostUguu, true)
+			host.CacheMock.On("GetString", "subsonic.artistimage.artist1").Return("https://img.example.com/m.jpg", true, nil)
+			host.CacheMock.On("GetString", "uguu.artwork.artist.artist1").Return(contentHash(fakeArt), true, nil)
+			host.CacheMock.On("GetString", "uguu.artwork."+contentHash(fakeArt)).Return("https://a.uguu.se/artist.jpg", true, nil)
+
+			Expect(getArtistImage("testuser", "artist1", 200)).To(Equal("https://a.uguu.se/artist.jpg"))
+			host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "CallRaw", mock.Anything)
+		})
+
+		It("caches artists without an image", func() {
+			host.CacheMock.On("GetString", "subsonic.artistimage.artist1").Return("", false, nil)
+			host.SubsonicAPIMock.On("Call", mock.Anything).Return(`{"subsonic-response":{"status":"ok","artistInfo2":{}}}`, nil)
+
+			Expect(getArtistImage("testuser", "artist1", 200)).To(BeEmpty())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "subsonic.artistimage.artist1", "", artistImageMissTTL)
+		})
+
+		It("doesn't cache failed requests", func() {
+			host.CacheMock.On("GetString", "subsonic.artistimage.artist1").Return("", false, nil)
+			host.SubsonicAPIMock.On("Call", mock.Anything).Return("", errors.New("unavailable"))
+
+			Expect(getArtistImage("testuser", "artist1", 200)).To(BeEmpty())
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", mock.Anything, mock.Anything, mock.Anything)
+		})
+	})
+
+	Describe("getSmallImage", func() {
+		It("defaults to the Navidrome logo", func() {
+			pdk.PDKMock.On("GetConfig", smallImageKey).Return("", false)
+
+			imageURL, text := getSmallImage("testuser", track)
+			Expect(imageURL).To(Equal(navidromeLogoURL))
+			Expect(text).To(BeEmpty())
+		})
+
+		It("uses a custom URL", func() {
+			pdk.PDKMock.On("GetConfig", smallImageKey).Return(smallImageCustom, true)
+			pdk.PDKMock.On("GetConfig", smallImageURLKey).Return(" https://example.com/logo.png ", true)
+
+			imageURL, _ := getSmallImage("testuser", track)
+			Expect(imageURL).To(Equal("https://example.com/logo.png"))
+		})
+
+		It("falls back to the logo for invalid custom URLs", func() {
+			pdk.PDKMock.On("GetConfig", smallImageKey).Return(smallImageCustom, true)
+			pdk.PDKMock.On("GetConfig", smallImageURLKey).Return("logo.png", true)
+
+			imageURL, _ := getSmallImage("testuser", track)
+			Expect(imageURL).To(Equal(navidromeLogoURL))
+		})
+
+		It("uses the cached Discord avatar", func() {
+			pdk.PDKMock.On("GetConfig", smallImageKey).Return(smallImageAvatar, true)
+			host.CacheMock.On("GetString", "discord.avatar.testuser").Return("https://cdn.discordapp.com/avatars/123/abc.png?size=128", true, nil)
+
+			imageURL, _ := getSmallImage("testuser", track)
+			Expect(imageURL).To(Equal("https://cdn.discordapp.com/avatars/123/abc.png?size=128"))
+		})
+
+		It("uses the image of the first artist known to Navidrome, named in the hover text", func() {
+			pdk.PDKMock.On("GetConfig", smallImageKey).Return(smallImageArtist, true)
+			pdk.PDKMock.On("GetConfig", imageHostKey).Return("", false)
+			pdk.PDKMock.On("GetConfig", uguuEnabledKey).Return("", false)
+			host.CacheMock.On("GetString", "subsonic.artistimage.artist1").Return("https://img.example.com/m.jpg", true, nil)
+
+			imageURL, text := getSmallImage("testuser", track)
+			Expect(imageURL).To(Equal("https://img.example.com/m.jpg"))
+			Expect(text).To(Equal("Radiohead"))
+		})
+
+		It("shows the format badge with matching hover text", func() {
+			pdk.PDKMock.On("GetConfig", smallImageKey).Return(smallImageFormat, true)
+			pdk.PDKMock.On("GetConfig", badgeBaseURLKey).Return("", false)
+			host.CacheMock.On("GetString", "subsonic.song.testuser.track1").Return(`{"id":"track1","suffix":"flac","bitDepth":24,"samplingRate":96000}`, true, nil)
+
+			imageURL, text := getSmallImage("testuser", track)
+			Expect(imageURL).To(Equal(defaultBadgeBaseURL + "flac-hires.png"))
+			Expect(text).To(Equal("FLAC 24/96"))
+		})
+
+		It("serves the format badge from the configured base URL", func() {
+			pdk.PDKMock.On("GetConfig", smallImageKey).Return(smallImageFormat, true)
+			pdk.PDKMock.On("GetConfig", badgeBaseURLKey).Return(" https://example.com/badges ", true)
+			host.CacheMock.On("GetString", "subsonic.song.testuser.track1").Return(`{"id":"track1","suffix":"mp3","bitRate":320}`, true, nil)
+
+			imageURL, _ := getSmallImage("testuser", track)
+			Expect(imageURL).To(Equal("https://example.com/badges/mp3.png"))
+		})
+
+		It("falls back to the logo when the format is unknown", func() {
+			pdk.PDKMock.On("GetConfig", smallImageKey).Return(smallImageFormat, true)
+			host.CacheMock.On("GetString", "subsonic.song.testuser.track1").Return(`{"id":"track1"}`, true, nil)
+
+			imageURL, text := getSmallImage("testuser", track)
+			Expect(imageURL).To(Equal(navidromeLogoURL))
+			Expect(text).To(BeEmpty())
+		})
+	})
+})
@@ -2,9 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/navidrome/navidrome/plugins/pdk/go/host"
 	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
@@ -23,11 +25,6 @@ func hashKey(s string) string {
 	return fmt.Sprintf("%016x", h)
 }
 
-const (
-	spotifyCacheTTLHit  int64 = 30 * 24 * 60 * 60 // 30 days for resolved track IDs
-	spotifyCacheTTLMiss int64 = 4 * 60 * 60       // 4 hours for misses (retry later)
-)
-
 // listenBrainzResult captures the relevant field from ListenBrainz Labs JSON responses.
 // The API returns spotify_track_ids as an array of strings.
 type listenBrainzResult struct {
@@ -46,11 +43,16 @@ func spotifySearchURL(terms ...string) string {
 
 // spotifyCacheKey returns a deterministic cache key for a track's Spotify URL.
 func spotifyCacheKey(artist, title, album string) string {
-	return "spotify.url." + hashKey(strings.ToLower(artist)+"\x00"+strings.ToLower(title)+"\x00"+strings.ToLower(album))
+	return linkCacheKey(linkServiceSpotify, artist, title, album)
 }
 
-// trySpotifyFromMBID calls the ListenBrainz spotify-id-from-mbid endpoint.
-func trySpotifyFromMBID(mbid string) string {
+// trySpotifyFromMBID calls the ListenBrainz spotify-id-from-mbid endpoint. It returns
+// an error when the request fails, and errServiceUnavailable without a request while the
+// ListenBrainz circuit breaker is open.
+func trySpotifyFromMBID(mbid string) (string, error) {
+	if !listenBrainzBreaker.allow(time.Now()) {
+		return "", errServiceUnavailable
+	}
 	body := fmt.Sprintf(`[{"recording_mbid":%q}]`, mbid)
 	resp, err := host.HTTPSend(host.HTTPRequest{
 		Method:  "POST",
@@ -58,23 +60,29 @@ func trySpotifyFromMBID(mbid string) string {
 		Headers: map[string]string{"Content-Type": "application/json"},
 		Body:    []byte(body),
 	})
+	listenBrainzBreaker.report(isServiceFailure(resp, err), time.Now())
 	if err != nil {
 		pdk.Log(pdk.LogInfo, fmt.Sprintf("ListenBrainz MBID lookup request failed: %v", err))
-		return ""
+		return "", fmt.Errorf("ListenBrainz MBID lookup failed: %w", err)
 	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		pdk.Log(pdk.LogDebug, fmt.Sprintf("ListenBrainz MBID lookup failed: HTTP %d, body=%s", resp.StatusCode, string(resp.Body)))
-		return ""
+		return "", fmt.Errorf("ListenBrainz MBID lookup failed: HTTP %d", resp.StatusCode)
 	}
 	id := parseSpotifyID(resp.Body)
 	if id == "" {
 		pdk.Log(pdk.LogDebug, fmt.Sprintf("ListenBrainz MBID lookup returned no spotify_track_id for mbid=%s, body=%s", mbid, string(resp.Body)))
 	}
-	return id
+	return id, nil
 }
 
-// trySpotifyFromMetadata calls the ListenBrainz spotify-id-from-metadata endpoint.
-func trySpotifyFromMetadata(artist, title, album string) string {
+// trySpotifyFromMetadata calls the ListenBrainz spotify-id-from-metadata endpoint. It returns
+// an error when the request fails, and errServiceUnavailable without a request while the
+// ListenBrainz circuit breaker is open.
+func trySpotifyFromMetadata(artist, title, album string) (string, error) {
+	if !listenBrainzBreaker.allow(time.Now()) {
+		return "", errServiceUnavailable
+	}
 	payload := fmt.Sprintf(`[{"artist_name":%q,"track_name":%q,"release_name":%q}]`, artist, title, album)
 
 	pdk.Log(pdk.LogDebug, fmt.Sprintf("ListenBrainz metadata request: %s", payload))
@@ -85,20 +93,21 @@ func trySpotifyFromMetadata(artist, title, album string) string {
 		Headers: map[string]string{"Content-Type": "application/json"},
 		Body:    []byte(payload),
 	})
+	listenBrainzBreaker.report(isServiceFailure(resp, err), time.Now())
 	if err != nil {
 		pdk.Log(pdk.LogInfo, fmt.Sprintf("ListenBrainz metadata lookup request failed: %v", err))
-		return ""
+		return "", fmt.Errorf("ListenBrainz metadata lookup failed: %w", err)
 	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		pdk.Log(pdk.LogDebug, fmt.Sprintf("ListenBrainz metadata lookup failed: HTTP %d, body=%s", resp.StatusCode, string(resp.Body)))
-		return ""
+		return "", fmt.Errorf("ListenBrainz metadata lookup failed: HTTP %d", resp.StatusCode)
 	}
 	pdk.Log(pdk.LogDebug, fmt.Sprintf("ListenBrainz metadata response: HTTP %d, body=%s", resp.StatusCode, string(resp.Body)))
 	id := parseSpotifyID(resp.Body)
 	if id == "" {
 		pdk.Log(pdk.LogDebug, fmt.Sprintf("ListenBrainz metadata returned no spotify_track_id for %q - %q", artist, title))
 	}
-	return id
+	return id, nil
 }
 
 // parseSpotifyID extracts the first spotify track ID from a ListenBrainz Labs JSON response.
@@ -132,49 +141,120 @@ func isValidSpotifyID(id string) bool {
 	return true
 }
 
-// resolveSpotifyURL resolves a direct Spotify track URL via ListenBrainz Labs,
-// falling back to a search URL. Results are cached.
-func resolveSpotifyURL(track scrobbler.TrackInfo) string {
-	var primary string
-	if len(track.Artists) > 0 {
-		primary = track.Artists[0].Name
-	}
+// spotifyResolver resolves Spotify track links via ListenBrainz Labs.
+type spotifyResolver struct{}
 
-	cacheKey := spotifyCacheKey(primary, track.Title, track.Album)
+func (spotifyResolver) Name() string { return linkServiceSpotify }
 
-	if cached, exists, err := host.CacheGetString(cacheKey); err == nil && exists {
-		pdk.Log(pdk.LogDebug, fmt.Sprintf("Spotify URL cache hit for %q - %q → %s", primary, track.Title, cached))
-		return cached
-	}
+// TrackURL looks the track up by its MBID first, then by the primary artist, title and album.
+func (spotifyResolver) TrackURL(track scrobbler.TrackInfo) (string, error) {
+	primary := primaryArtist(track)
 
 	pdk.Log(pdk.LogDebug, fmt.Sprintf("Resolving Spotify URL for: artist=%q title=%q album=%q mbid=%q", primary, track.Title, track.Album, track.MBZRecordingID))
 
-	// 1. Try MBID lookup (most accurate)
-	if track.MBZRecordingID != "" {
-		if trackID := trySpotifyFromMBID(track.MBZRecordingID); trackID != "" {
-			directURL := "https://open.spotify.com/track/" + trackID
-			_ = host.CacheSetString(cacheKey, directURL, spotifyCacheTTLHit)
-			pdk.Log(pdk.LogInfo, fmt.Sprintf("Resolved Spotify via MBID for %q: %s", track.Title, directURL))
-			return directURL
+	// Untagged tracks may get their MBID from a MusicBrainz search, which is pointless
+	// while ListenBrainz is being skipped
+	mbid := track.MBZRecordingID
+	if mbid == "" {
+		if listenBrainzBreaker.isOpen(time.Now()) {
+			return "", errServiceUnavailable
+		}
+		mbid = lookupRecordingMBID(track)
+	}
+
+	// 1. Try MBID lookup (most accurate). A failed request still lets the metadata
+	// lookups run, but a miss is then reported as a failure.
+	var mbidErr error
+	if mbid != "" {
+		trackID, err := trySpotifyFromMBID(mbid)
+		if errors.Is(err, errServiceUnavailable) {
+			return "", err
+		}
+		if trackID != "" {
+			return "https://open.spotify.com/track/" + trackID, nil
 		}
+		mbidErr = err
 		pdk.Log(pdk.LogDebug, "MBID lookup did not return a Spotify ID, trying metadata…")
 	} else {
-		pdk.Log(pdk.LogDebug, "No MBZRecordingID available, skipping MBID lookup")
+		pdk.Log(pdk.LogDebug, "No recording MBID available, skipping MBID lookup")
+	}
+
+	// 2. Try metadata lookups, from the tags as they are to simpler variants.
+	// A failed request ends the retries, as the variants would fail as well.
+	for _, q := range metadataVariants(primary, track.Title, track.Album) {
+		trackID, err := trySpotifyFromMetadata(q.Artist, q.Title, q.Album)
+		if err != nil {
+			return "", err
+		}
+		if trackID != "" {
+			return "https://open.spotify.com/track/" + trackID, nil
+		}
 	}
+	return "", mbidErr
+}
+
+func (spotifyResolver) SearchURL(terms ...string) string {
+	return spotifySearchURL(terms...)
+}
 
-	// 2. Try metadata lookup
-	if primary != "" && track.Title != "" {
-		if trackID := trySpotifyFromMetadata(primary, track.Title, track.Album); trackID != "" {
-			directURL := "https://open.spotify.com/track/" + trackID
-			_ = host.CacheSetString(cacheKey, directURL, spotifyCacheTTLHit)
-			pdk.Log(pdk.LogInfo, fmt.Sprintf("Resolved Spotify via metadata for %q - %q: %s", primary, track.Title, directURL))
-			return directURL
+// ArtistURL links the primary artist's Spotify page when its MBID has a Spotify
+// relationship on MusicBrainz, falling back to a search for the artist. ListenBrainz
+// Labs isn't asked: its Spotify endpoints map recordings to track IDs only, and have no
+// artist lookup.
+func (spotifyResolver) ArtistURL(track scrobbler.TrackInfo, artist string) string {
+	if len(track.Artists) > 0 && isValidMBID(track.Artists[0].MBID) {
+		mbid := strings.ToLower(track.Artists[0].MBID)
+		link := cachedLookup("spotify.artist."+mbid, func() (string, error) {
+			return trySpotifyFromURLRels("artist", mbid, "artist")
+		})
+		if link != "" {
+			return link
 		}
 	}
+	return spotifySearchURL(artist)
+}
+
+// AlbumURL links the Spotify album when the release MBID has a Spotify relationship on
+// MusicBrainz. Otherwise the album art links to the track. As for artists, ListenBrainz
+// Labs has no album lookup to fall back to.
+func (spotifyResolver) AlbumURL(track scrobbler.TrackInfo) string {
+	if !isValidMBID(track.MBZAlbumID) {
+		return ""
+	}
+	mbid := strings.ToLower(track.MBZAlbumID)
+	return cachedLookup("spotify.album."+mbid, func() (string, error) {
+		return trySpotifyFromURLRels("release", mbid, "album")
+	})
+}
+
+// trySpotifyFromURLRels looks up the Spotify page of a MusicBrainz entity in its URL relationships.
+// kind is the Spotify page type, "artist" or "album". Failed lookups return an error, so
+// they aren't cached as misses.
+func trySpotifyFromURLRels(entity, mbid, kind string) (string, error) {
+	urls, err := musicBrainzURLRels(entity, mbid)
+	if err != nil {
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("MusicBrainz %s lookup failed: %v", entity, err))
+		return "", err
+	}
+	link := spotifyLinkFromURLs(urls, kind)
+	if link == "" {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("MusicBrainz %s %s has no Spotify %s link", entity, mbid, kind))
+	}
+	return link, nil
+}
 
-	// 3. Fallback to search URL
-	searchURL := spotifySearchURL(track.Artist, track.Title)
-	_ = host.CacheSetString(cacheKey, searchURL, spotifyCacheTTLMiss)
-	pdk.Log(pdk.LogInfo, fmt.Sprintf("Spotify resolution missed, falling back to search URL for %q - %q: %s", primary, track.Title, searchURL))
-	return searchURL
+// spotifyLinkFromURLs returns the first Spotify page of the given kind among urls, in canonical form.
+func spotifyLinkFromURLs(urls []string, kind string) string {
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil || parsed.Host != "open.spotify.com" {
+			continue
+		}
+		// Paths may carry a locale prefix, e.g. /intl-de/artist/<id>
+		parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+		if n := len(parts); n >= 2 && parts[n-2] == kind && isValidSpotifyID(parts[n-1]) {
+			return "https://open.spotify.com/" + kind + "/" + parts[n-1]
+		}
+	}
+	return ""
 }
@@ -2,7 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/navidrome/navidrome/plugins/pdk/go/host"
 	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
@@ -51,6 +54,13 @@ var _ = Describe("Spotify", func() {
 		})
 	})
 
+	Describe("spotifyCacheKey normalization", func() {
+		It("ignores typographic and compatibility differences", func() {
+			Expect(spotifyCacheKey("Guns N’ Roses", "Don’t Cry", "Use Your Illusion I")).
+				To(Equal(spotifyCacheKey("guns n' roses", "DON'T CRY", "Use Your  Illusion I")))
+		})
+	})
+
 	Describe("parseSpotifyID", func() {
 		DescribeTable("extracts first Spotify track ID from ListenBrainz response",
 			func(body, expectedID string) {
@@ -113,7 +123,7 @@ var _ = Describe("Spotify", func() {
 		})
 	})
 
-	Describe("resolveSpotifyURL", func() {
+	Describe("spotifyResolver track links", func() {
 		BeforeEach(func() {
 			pdk.ResetMock()
 			host.CacheMock.ExpectedCalls = nil
@@ -121,17 +131,24 @@ var _ = Describe("Spotify", func() {
 			host.HTTPMock.ExpectedCalls = nil
 			host.HTTPMock.Calls = nil
 			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+
+			// MusicBrainz lookups use their defaults
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false).Maybe()
+
+			// ListenBrainz circuit breaker is closed
+			host.CacheMock.On("GetString", listenBrainzBreaker.cacheKey()).Return("", false, nil).Maybe()
+			host.CacheMock.On("SetString", listenBrainzBreaker.cacheKey(), mock.Anything, mock.Anything).Return(nil).Maybe()
 		})
 
 		It("returns cached URL on cache hit", func() {
 			host.CacheMock.On("GetString", spotifyURLKey).Return("https://open.spotify.com/track/cached123", true, nil)
 
-			url := resolveSpotifyURL(scrobbler.TrackInfo{
+			url, _ := resolveTrackLink(spotifyResolver{}, scrobbler.TrackInfo{
 				Title:   "Karma Police",
 				Artist:  "Radiohead",
 				Artists: []scrobbler.ArtistRef{{Name: "Radiohead"}},
 				Album:   "OK Computer",
-			})
+			}, "Radiohead")
 			Expect(url).To(Equal("https://open.spotify.com/track/cached123"))
 		})
 
@@ -144,15 +161,15 @@ var _ = Describe("Spotify", func() {
 				return req.URL == "https://labs.api.listenbrainz.org/spotify-id-from-mbid/json"
 			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":["63OQupATfueTdZMWIV7nzz"]}]`)}, nil)
 
-			url := resolveSpotifyURL(scrobbler.TrackInfo{
+			url, _ := resolveTrackLink(spotifyResolver{}, scrobbler.TrackInfo{
 				Title:          "Karma Police",
 				Artist:         "Radiohead",
 				Artists:        []scrobbler.ArtistRef{{Name: "Radiohead"}},
 				Album:          "OK Computer",
 				MBZRecordingID: "mbid-123",
-			})
+			}, "Radiohead")
 			Expect(url).To(Equal("https://open.spotify.com/track/63OQupATfueTdZMWIV7nzz"))
-			host.CacheMock.AssertCalled(GinkgoT(), "SetString", spotifyURLKey, "https://open.spotify.com/track/63OQupATfueTdZMWIV7nzz", spotifyCacheTTLHit)
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", spotifyURLKey, "https://open.spotify.com/track/63OQupATfueTdZMWIV7nzz", linkCacheTTLHit)
 		})
 
 		It("falls back to metadata lookup when MBID fails", func() {
@@ -169,13 +186,13 @@ var _ = Describe("Spotify", func() {
 				return req.URL == "https://labs.api.listenbrainz.org/spotify-id-from-metadata/json"
 			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":["4wlLbLeDWbA6TzwZFp1UaK"]}]`)}, nil)
 
-			url := resolveSpotifyURL(scrobbler.TrackInfo{
+			url, _ := resolveTrackLink(spotifyResolver{}, scrobbler.TrackInfo{
 				Title:          "Karma Police",
 				Artist:         "Radiohead",
 				Artists:        []scrobbler.ArtistRef{{Name: "Radiohead"}},
 				Album:          "OK Computer",
 				MBZRecordingID: "mbid-123",
-			})
+			}, "Radiohead")
 			Expect(url).To(Equal("https://open.spotify.com/track/4wlLbLeDWbA6TzwZFp1UaK"))
 		})
 
@@ -188,15 +205,136 @@ var _ = Describe("Spotify", func() {
 				return req.URL == "https://labs.api.listenbrainz.org/spotify-id-from-metadata/json"
 			})).Return(&host.HTTPResponse{StatusCode: 500, Body: []byte(`error`)}, nil)
 
-			url := resolveSpotifyURL(scrobbler.TrackInfo{
+			url, _ := resolveTrackLink(spotifyResolver{}, scrobbler.TrackInfo{
 				Title:   "Karma Police",
 				Artist:  "Radiohead",
 				Artists: []scrobbler.ArtistRef{{Name: "Radiohead"}},
 				Album:   "OK Computer",
-			})
+			}, "Radiohead")
 			Expect(url).To(HavePrefix("https://open.spotify.com/search/"))
 			Expect(url).To(ContainSubstring("Radiohead"))
-			host.CacheMock.AssertCalled(GinkgoT(), "SetString", spotifyURLKey, mock.Anything, spotifyCacheTTLMiss)
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", spotifyURLKey, mock.Anything, linkCacheTTLUnavailable)
+		})
+
+		It("caches the search fallback only briefly when the MBID lookup failed", func() {
+			host.CacheMock.On("GetString", spotifyURLKey).Return("", false, nil)
+			host.CacheMock.On("SetString", spotifyURLKey, mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return req.URL == "https://labs.api.listenbrainz.org/spotify-id-from-mbid/json"
+			})).Return((*host.HTTPResponse)(nil), errors.New("connection reset"))
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":[]}]`)}, nil)
+
+			url, _ := resolveTrackLink(spotifyResolver{}, scrobbler.TrackInfo{
+				Title:          "Karma Police",
+				Artists:        []scrobbler.ArtistRef{{Name: "Radiohead"}},
+				Album:          "OK Computer",
+				MBZRecordingID: "mbid-123",
+			}, "Radiohead")
+			Expect(url).To(Equal(spotifySearchURL("Radiohead", "Karma Police")))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", spotifyURLKey, url, linkCacheTTLUnavailable)
+		})
+
+		It("caches a real miss with the miss TTL", func() {
+			host.CacheMock.On("GetString", spotifyURLKey).Return("", false, nil)
+			host.CacheMock.On("SetString", spotifyURLKey, mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":[]}]`)}, nil)
+
+			url, _ := resolveTrackLink(spotifyResolver{}, scrobbler.TrackInfo{
+				Title:   "Karma Police",
+				Artists: []scrobbler.ArtistRef{{Name: "Radiohead"}},
+			}, "Radiohead")
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", spotifyURLKey, url, linkCacheTTLMiss)
+		})
+
+		It("retries the metadata lookup with simpler variants", func() {
+			host.CacheMock.On("GetString", spotifyURLKey).Return("", false, nil)
+			host.CacheMock.On("SetString", spotifyURLKey, mock.Anything, mock.Anything).Return(nil)
+
+			var bodies []string
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return req.URL == "https://labs.api.listenbrainz.org/spotify-id-from-metadata/json"
+			})).Run(func(args mock.Arguments) {
+				bodies = append(bodies, string(args.Get(0).(host.HTTPRequest).Body))
+			}).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":[]}]`)}, nil).Once()
+			host.HTTPMock.On("Send", mock.Anything).Run(func(args mock.Arguments) {
+				bodies = append(bodies, string(args.Get(0).(host.HTTPRequest).Body))
+			}).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":["63OQupATfueTdZMWIV7nzz"]}]`)}, nil)
+
+			url, _ := resolveTrackLink(spotifyResolver{}, scrobbler.TrackInfo{
+				Title:   "Karma Police (2011 Remaster)",
+				Artists: []scrobbler.ArtistRef{{Name: "Radiohead"}},
+				Album:   "OK Computer",
+			}, "Radiohead")
+			Expect(url).To(Equal("https://open.spotify.com/track/63OQupATfueTdZMWIV7nzz"))
+			Expect(bodies).To(HaveLen(2))
+			Expect(bodies[0]).To(ContainSubstring(`"track_name":"Karma Police (2011 Remaster)"`))
+			Expect(bodies[1]).To(ContainSubstring(`"track_name":"Karma Police"`))
+		})
+
+		It("stops retrying when a lookup request fails", func() {
+			host.CacheMock.On("GetString", spotifyURLKey).Return("", false, nil)
+			host.CacheMock.On("SetString", spotifyURLKey, mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 500}, nil)
+
+			resolveTrackLink(spotifyResolver{}, scrobbler.TrackInfo{
+				Title:   "Karma Police (2011 Remaster)",
+				Artists: []scrobbler.ArtistRef{{Name: "Radiohead"}},
+				Album:   "OK Computer",
+			}, "Radiohead")
+			host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", 1)
+		})
+
+		It("skips ListenBrainz while its circuit breaker is open", func() {
+			host.CacheMock.ExpectedCalls = nil
+			openUntil := time.Now().Unix() + 60
+			host.CacheMock.On("GetString", listenBrainzBreaker.cacheKey()).Return(fmt.Sprintf(`{"failures":3,"openUntil":%d}`, openUntil), true, nil)
+			host.CacheMock.On("GetString", spotifyURLKey).Return("", false, nil)
+			host.CacheMock.On("SetString", spotifyURLKey, mock.Anything, mock.Anything).Return(nil)
+
+			url, _ := resolveTrackLink(spotifyResolver{}, scrobbler.TrackInfo{
+				Title:          "Karma Police",
+				Artist:         "Radiohead",
+				Artists:        []scrobbler.ArtistRef{{Name: "Radiohead"}},
+				Album:          "OK Computer",
+				MBZRecordingID: "mbid-123",
+			}, "Radiohead")
+			Expect(url).To(Equal(spotifySearchURL("Radiohead", "Karma Police")))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", spotifyURLKey, url, linkCacheTTLUnavailable)
+		})
+
+		It("records ListenBrainz server errors with the circuit breaker", func() {
+			host.CacheMock.On("GetString", spotifyURLKey).Return("", false, nil)
+			host.CacheMock.On("SetString", spotifyURLKey, mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 503}, nil)
+
+			resolveTrackLink(spotifyResolver{}, scrobbler.TrackInfo{
+				Title:   "Karma Police",
+				Artists: []scrobbler.ArtistRef{{Name: "Radiohead"}},
+			}, "Radiohead")
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", listenBrainzBreaker.cacheKey(), `{"failures":1}`, breakerStateTTL)
+		})
+
+		It("uses the formatted artist for the search fallback", func() {
+			host.CacheMock.On("GetString", spotifyURLKey).Return("", false, nil)
+			host.CacheMock.On("SetString", spotifyURLKey, mock.Anything, mock.Anything).Return(nil)
+
+			var metadataBody string
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return req.URL == "https://labs.api.listenbrainz.org/spotify-id-from-metadata/json"
+			})).Run(func(args mock.Arguments) {
+				metadataBody = string(args.Get(0).(host.HTTPRequest).Body)
+			}).Return(&host.HTTPResponse{StatusCode: 500, Body: []byte(`error`)}, nil)
+
+			url, _ := resolveTrackLink(spotifyResolver{}, scrobbler.TrackInfo{
+				Title:   "Collab",
+				Artist:  "A, B, C, D, E",
+				Artists: []scrobbler.ArtistRef{{Name: "A"}, {Name: "B"}, {Name: "C"}, {Name: "D"}, {Name: "E"}},
+				Album:   "Album",
+			}, "A feat. B, C, D & E")
+			Expect(url).To(Equal(spotifySearchURL("A feat. B, C, D & E", "Collab")))
+			Expect(metadataBody).To(ContainSubstring(`"artist_name":"A"`))
+			host.CacheMock.AssertCalled(GinkgoT(), "GetString", spotifyCacheKey("A feat. B, C, D & E", "Collab", "Album"))
 		})
 
 		It("uses Artists[0] for primary artist", func() {
@@ -207,13 +345,160 @@ var _ = Describe("Spotify", func() {
 				return req.URL == "https://labs.api.listenbrainz.org/spotify-id-from-metadata/json"
 			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":["4tIGK5G9hNDA50ZdGioZRG"]}]`)}, nil)
 
-			url := resolveSpotifyURL(scrobbler.TrackInfo{
+			url, _ := resolveTrackLink(spotifyResolver{}, scrobbler.TrackInfo{
 				Title:   "Some Song",
 				Artist:  "",
 				Album:   "Some Album",
 				Artists: []scrobbler.ArtistRef{{Name: "Fallback Artist"}},
-			})
+			}, "Fallback Artist")
 			Expect(url).To(Equal("https://open.spotify.com/track/4tIGK5G9hNDA50ZdGioZRG"))
 		})
 	})
+
+	Describe("spotifyLinkFromURLs", func() {
+		DescribeTable("finds Spotify pages of the requested kind",
+			func(urls []string, kind, expected string) {
+				Expect(spotifyLinkFromURLs(urls, kind)).To(Equal(expected))
+			},
+			Entry("artist page", []string{"https://www.radiohead.com/", "https://open.spotify.com/artist/4Z8W4fKeB5YxbusRsdQVPb"}, "artist", "https://open.spotify.com/artist/4Z8W4fKeB5YxbusRsdQVPb"),
+			Entry("localized album page", []string{"https://open.spotify.com/intl-de/album/6dVIqQ8qmQ5GBnJ9shOYGE?si=x"}, "album", "https://open.spotify.com/album/6dVIqQ8qmQ5GBnJ9shOYGE"),
+			Entry("wrong kind", []string{"https://open.spotify.com/album/6dVIqQ8qmQ5GBnJ9shOYGE"}, "artist", ""),
+			Entry("other hosts", []string{"https://spotify.example.com/artist/4Z8W4fKeB5YxbusRsdQVPb"}, "artist", ""),
+			Entry("invalid ID", []string{"https://open.spotify.com/artist/not-an-id"}, "artist", ""),
+		)
+	})
+
+	Describe("spotifyResolver artist and album links", func() {
+		const artistMBID = "a74b1b7f-71a5-4011-9441-d0b5e4122711"
+		const releaseMBID = "52709206-8816-3c12-9ff6-f957f2f1eecf"
+		track := scrobbler.TrackInfo{
+			Title:      "Karma Police",
+			Album:      "OK Computer",
+			Artists:    []scrobbler.ArtistRef{{Name: "Radiohead", MBID: artistMBID}},
+			MBZAlbumID: releaseMBID,
+		}
+
+		BeforeEach(func() {
+			pdk.ResetMock()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.Calls = nil
+			host.HTTPMock.ExpectedCalls = nil
+			host.HTTPMock.Calls = nil
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false).Maybe()
+			host.CacheMock.On("GetString", musicBrainzThrottleKey).Return("", false, nil).Maybe()
+			host.CacheMock.On("SetString", musicBrainzThrottleKey, mock.Anything, mock.Anything).Return(nil).Maybe()
+		})
+
+		It("returns the cached artist link", func() {
+			host.CacheMock.On("GetString", "spotify.artist."+artistMBID).Return("https://open.spotify.com/artist/4Z8W4fKeB5YxbusRsdQVPb", true, nil)
+
+			Expect(spotifyResolver{}.ArtistURL(track, "Radiohead")).To(Equal("https://open.spotify.com/artist/4Z8W4fKeB5YxbusRsdQVPb"))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+
+		It("resolves the artist through MusicBrainz url-rels and caches it", func() {
+			host.CacheMock.On("GetString", "spotify.artist."+artistMBID).Return("", false, nil)
+			host.CacheMock.On("SetString", "spotify.artist."+artistMBID, mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return req.URL == "https://musicbrainz.org/ws/2/artist/"+artistMBID+"?inc=url-rels&fmt=json"
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"relations":[{"url":{"resource":"https://open.spotify.com/artist/4Z8W4fKeB5YxbusRsdQVPb"}}]}`)}, nil)
+
+			Expect(spotifyResolver{}.ArtistURL(track, "Radiohead")).To(Equal("https://open.spotify.com/artist/4Z8W4fKeB5YxbusRsdQVPb"))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "spotify.artist."+artistMBID, "https://open.spotify.com/artist/4Z8W4fKeB5YxbusRsdQVPb", linkCacheTTLHit)
+		})
+
+		It("falls back to an artist search and caches the miss", func() {
+			host.CacheMock.On("GetString", "spotify.artist."+artistMBID).Return("", false, nil)
+			host.CacheMock.On("SetString", "spotify.artist."+artistMBID, "", linkCacheTTLMiss).Return(nil)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"relations":[]}`)}, nil)
+
+			Expect(spotifyResolver{}.ArtistURL(track, "Radiohead")).To(Equal(spotifySearchURL("Radiohead")))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "spotify.artist."+artistMBID, "", linkCacheTTLMiss)
+		})
+
+		It("caches failed artist lookups only briefly", func() {
+			host.CacheMock.On("GetString", "spotify.artist."+artistMBID).Return("", false, nil)
+			host.CacheMock.On("SetString", "spotify.artist."+artistMBID, "", linkCacheTTLUnavailable).Return(nil)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 503}, nil)
+
+			Expect(spotifyResolver{}.ArtistURL(track, "Radiohead")).To(Equal(spotifySearchURL("Radiohead")))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "spotify.artist."+artistMBID, "", linkCacheTTLUnavailable)
+		})
+
+		It("searches for artists without an MBID", func() {
+			noMBID := track
+			noMBID.Artists = []scrobbler.ArtistRef{{Name: "Radiohead"}}
+
+			Expect(spotifyResolver{}.ArtistURL(noMBID, "Radiohead")).To(Equal(spotifySearchURL("Radiohead")))
+			host.CacheMock.AssertNotCalled(GinkgoT(), "GetString", mock.Anything)
+		})
+
+		It("resolves the album from the release MBID", func() {
+			host.CacheMock.On("GetString", "spotify.album."+releaseMBID).Return("", false, nil)
+			host.CacheMock.On("SetString", "spotify.album."+releaseMBID, mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return req.URL == "https://musicbrainz.org/ws/2/release/"+releaseMBID+"?inc=url-rels&fmt=json"
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"relations":[{"url":{"resource":"https://open.spotify.com/album/6dVIqQ8qmQ5GBnJ9shOYGE"}}]}`)}, nil)
+
+			Expect(spotifyResolver{}.AlbumURL(track)).To(Equal("https://open.spotify.com/album/6dVIqQ8qmQ5GBnJ9shOYGE"))
+		})
+
+		It("has no album link without a release MBID", func() {
+			noMBID := track
+			noMBID.MBZAlbumID = ""
+
+			Expect(spotifyResolver{}.AlbumURL(noMBID)).To(BeEmpty())
+		})
+	})
+
+	Describe("MBID enrichment", func() {
+		BeforeEach(func() {
+			pdk.ResetMock()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.Calls = nil
+			host.HTTPMock.ExpectedCalls = nil
+			host.HTTPMock.Calls = nil
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		})
+
+		It("skips the MusicBrainz search while the ListenBrainz circuit breaker is open", func() {
+			pdk.PDKMock.On("GetConfig", mbidLookupKey).Return("true", true)
+			openUntil := time.Now().Unix() + 60
+			host.CacheMock.On("GetString", listenBrainzBreaker.cacheKey()).Return(fmt.Sprintf(`{"failures":3,"openUntil":%d}`, openUntil), true, nil)
+
+			_, err := spotifyResolver{}.TrackURL(scrobbler.TrackInfo{
+				Title:   "Karma Police",
+				Artists: []scrobbler.ArtistRef{{Name: "Radiohead"}},
+			})
+			Expect(err).To(MatchError(errServiceUnavailable))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+
+		It("resolves untagged tracks through the MBID found by MusicBrainz search", func() {
+			pdk.PDKMock.On("GetConfig", mbidLookupKey).Return("true", true)
+			pdk.PDKMock.On("GetConfig", musicBrainzURLKey).Return("", false)
+			host.CacheMock.On("GetString", mock.Anything).Return("", false, nil)
+			host.CacheMock.On("SetString", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return strings.HasPrefix(req.URL, "https://musicbrainz.org/ws/2/recording?")
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"recordings":[
+				{"id":"b1a9c0e9-d987-4042-ae91-78d6a3267d69","score":100,"title":"Karma Police","artist-credit":[{"name":"Radiohead"}]}]}`)}, nil)
+
+			var mbidBody string
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return req.URL == "https://labs.api.listenbrainz.org/spotify-id-from-mbid/json"
+			})).Run(func(args mock.Arguments) {
+				mbidBody = string(args.Get(0).(host.HTTPRequest).Body)
+			}).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":["63OQupATfueTdZMWIV7nzz"]}]`)}, nil)
+
+			url, _ := resolveTrackLink(spotifyResolver{}, scrobbler.TrackInfo{
+				Title:   "Karma Police",
+				Artists: []scrobbler.ArtistRef{{Name: "Radiohead"}},
+				Album:   "OK Computer",
+			}, "Radiohead")
+			Expect(url).To(Equal("https://open.spotify.com/track/63OQupATfueTdZMWIV7nzz"))
+			Expect(mbidBody).To(ContainSubstring("b1a9c0e9-d987-4042-ae91-78d6a3267d69"))
+		})
+	})
 })
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// The resolution store keeps lookup results in the KVStore, so they survive restarts.
+// The host cache stays the hot tier: reads try the cache first and refill it from the
+// store, writes go to both. Long-lived results (such as track → Spotify URL) are kept
+// until evicted; temporary ones (such as uguu uploads) carry an expiry timestamp.
+const (
+	storeKeyPrefix     = "store."
+	storeMaxBytes      = 768 * 1024 // Evict once the KVStore grows past this, under the 1MB quota
+	storeEvictFraction = 4          // Evict 1/storeEvictFraction of the entries at a time
+)
+
+// storeEntry is a value kept in the resolution store.
+type storeEntry struct {
+	Value     string `json:"v"`
+	StoredAt  int64  `json:"s"`           // Unix seconds, used to evict the oldest entries first
+	ExpiresAt int64  `json:"e,omitempty"` // Unix seconds, 0 for entries that don't expire
+}
+
+func (e storeEntry) expired(now time.Time) bool {
+	return e.ExpiresAt != 0 && now.Unix() >= e.ExpiresAt
+}
+
+// storeLoad reads the entry stored under key. Expired or unreadable entries are deleted.
+func storeLoad(key string, now time.Time) (storeEntry, bool) {
+	var entry storeEntry
+	data, exists, err := host.KVStoreGet(storeKeyPrefix + key)
+	if err != nil || !exists {
+		return entry, false
+	}
+	if err := json.Unmarshal(data, &entry); err != nil || entry.expired(now) {
+		_ = host.KVStoreDelete(storeKeyPrefix + key)
+		return entry, false
+	}
+	return entry, true
+}
+
+// storeSave writes value under key. A ttl of 0 keeps the entry until it is evicted.
+func storeSave(key, value string, ttl int64, now time.Time) {
+	entry := storeEntry{Value: value, StoredAt: now.Unix()}
+	if ttl > 0 {
+		entry.ExpiresAt = now.Unix() + ttl
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := host.KVStoreSet(storeKeyPrefix+key, data); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to persist %s: %v", key, err))
+		return
+	}
+	storeEnforceLimit(now)
+}
+
+// storeEnforceLimit evicts entries once the store grows past storeMaxBytes: expired
+// entries first, then the oldest ones, a batch at a time so it doesn't run on every write.
+func storeEnforceLimit(now time.Time) {
+	used, err := host.KVStoreGetStorageUsed()
+	if err != nil || used <= storeMaxBytes {
+		return
+	}
+	keys, err := host.KVStoreList(storeKeyPrefix)
+	if err != nil || len(keys) == 0 {
+		return
+	}
+
+	type candidate struct {
+		key      string
+		storedAt int64
+	}
+	var live []candidate
+	expired := 0
+	for _, key := range keys {
+		entry, ok := storeLoad(strings.TrimPrefix(key, storeKeyPrefix), now)
+		if !ok {
+			expired++ // storeLoad already deleted it
+			continue
+		}
+		live = append(live, candidate{key: key, storedAt: entry.StoredAt})
+	}
+
+	evict := len(keys)/storeEvictFraction + 1 - expired
+	if evict <= 0 {
+		return
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].storedAt < live[j].storedAt })
+	evict = min(evict, len(live))
+	for _, c := range live[:evict] {
+		_ = host.KVStoreDelete(c.key)
+	}
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Resolution store over %d bytes (%d used), evicted %d expired and %d oldest entries", storeMaxBytes, used, expired, evict))
+}
+
+// tieredGet returns the value for key from the cache, or from the store. A store hit
+// refills the cache for cacheTTL, but never past the entry's expiry.
+func tieredGet(key string, cacheTTL int64) (string, bool) {
+	if cached, exists, err := host.CacheGetString(key); err == nil && exists {
+		return cached, true
+	}
+	now := time.Now()
+	entry, ok := storeLoad(key, now)
+	if !ok {
+		return "", false
+	}
+	if entry.ExpiresAt != 0 {
+		cacheTTL = min(cacheTTL, entry.ExpiresAt-now.Unix())
+	}
+	_ = host.CacheSetString(key, entry.Value, cacheTTL)
+	pdk.Log(pdk.LogDebug, fmt.Sprintf("Restored %s from the resolution store", key))
+	return entry.Value, true
+}
+
+// tieredSet caches value under key for cacheTTL and persists it in the store. storeTTL is
+// how long the value stays valid, or 0 for values that don't expire.
+func tieredSet(key, value string, cacheTTL, storeTTL int64) {
+	_ = host.CacheSetString(key, value, cacheTTL)
+	storeSave(key, value, storeTTL, time.Now())
+}
+
+// tieredRemove drops key from the cache and the store.
+func tieredRemove(key string) {
+	_ = host.CacheRemove(key)
+	_ = host.KVStoreDelete(storeKeyPrefix + key)
+}
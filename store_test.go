@@ -0,0 +1,144 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("resolution store", func() {
+	now := time.Unix(1_000_000, 0)
+
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.KVStoreMock.ExpectedCalls = nil
+		host.KVStoreMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	Describe("storeLoad", func() {
+		It("returns stored entries", func() {
+			host.KVStoreMock.On("Get", "store.key").Return([]byte(`{"v":"value","s":999000}`), true, nil)
+			entry, ok := storeLoad("key", now)
+			Expect(ok).To(BeTrue())
+			Expect(entry.Value).To(Equal("value"))
+		})
+
+		It("returns entries that have not expired yet", func() {
+			host.KVStoreMock.On("Get", "store.key").Return([]byte(`{"v":"value","s":999000,"e":1000001}`), true, nil)
+			_, ok := storeLoad("key", now)
+			Expect(ok).To(BeTrue())
+		})
+
+		It("deletes expired entries", func() {
+			host.KVStoreMock.On("Get", "store.key").Return([]byte(`{"v":"value","s":999000,"e":1000000}`), true, nil)
+			host.KVStoreMock.On("Delete", "store.key").Return(nil)
+			_, ok := storeLoad("key", now)
+			Expect(ok).To(BeFalse())
+			host.KVStoreMock.AssertCalled(GinkgoT(), "Delete", "store.key")
+		})
+
+		It("deletes unreadable entries", func() {
+			host.KVStoreMock.On("Get", "store.key").Return([]byte(`not json`), true, nil)
+			host.KVStoreMock.On("Delete", "store.key").Return(nil)
+			_, ok := storeLoad("key", now)
+			Expect(ok).To(BeFalse())
+			host.KVStoreMock.AssertCalled(GinkgoT(), "Delete", "store.key")
+		})
+
+		It("misses when nothing is stored", func() {
+			host.KVStoreMock.On("Get", "store.key").Return([]byte(nil), false, nil)
+			_, ok := storeLoad("key", now)
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("storeSave", func() {
+		BeforeEach(func() {
+			host.KVStoreMock.On("GetStorageUsed").Return(int64(1024), nil)
+		})
+
+		It("stores entries without expiry", func() {
+			host.KVStoreMock.On("Set", "store.key", []byte(`{"v":"value","s":1000000}`)).Return(nil)
+			storeSave("key", "value", 0, now)
+			host.KVStoreMock.AssertExpectations(GinkgoT())
+		})
+
+		It("records the expiry of temporary entries", func() {
+			host.KVStoreMock.On("Set", "store.key", []byte(`{"v":"value","s":1000000,"e":1000060}`)).Return(nil)
+			storeSave("key", "value", 60, now)
+			host.KVStoreMock.AssertExpectations(GinkgoT())
+		})
+	})
+
+	Describe("storeEnforceLimit", func() {
+		It("does nothing while under the limit", func() {
+			host.KVStoreMock.On("GetStorageUsed").Return(int64(storeMaxBytes), nil)
+			storeEnforceLimit(now)
+			host.KVStoreMock.AssertNotCalled(GinkgoT(), "List", mock.Anything)
+		})
+
+		It("evicts expired entries before the oldest ones", func() {
+			host.KVStoreMock.On("GetStorageUsed").Return(int64(storeMaxBytes+1), nil)
+			host.KVStoreMock.On("List", "store.").Return([]string{"store.a", "store.b", "store.c", "store.d", "store.e"}, nil)
+			host.KVStoreMock.On("Get", "store.a").Return([]byte(`{"v":"a","s":300}`), true, nil)
+			host.KVStoreMock.On("Get", "store.b").Return([]byte(`{"v":"b","s":100}`), true, nil)
+			host.KVStoreMock.On("Get", "store.c").Return([]byte(`{"v":"c","s":50,"e":900000}`), true, nil)
+			host.KVStoreMock.On("Get", "store.d").Return([]byte(`{"v":"d","s":200}`), true, nil)
+			host.KVStoreMock.On("Get", "store.e").Return([]byte(`{"v":"e","s":400}`), true, nil)
+			host.KVStoreMock.On("Delete", mock.Anything).Return(nil)
+
+			storeEnforceLimit(now)
+
+			// 5/4+1 = 2 entries: the expired one, then the oldest live one
+			host.KVStoreMock.AssertCalled(GinkgoT(), "Delete", "store.c")
+			host.KVStoreMock.AssertCalled(GinkgoT(), "Delete", "store.b")
+			host.KVStoreMock.AssertNumberOfCalls(GinkgoT(), "Delete", 2)
+		})
+	})
+
+	Describe("tieredGet", func() {
+		It("prefers the cache", func() {
+			host.CacheMock.On("GetString", "key").Return("cached", true, nil)
+			value, ok := tieredGet("key", 60)
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal("cached"))
+			host.KVStoreMock.AssertNotCalled(GinkgoT(), "Get", mock.Anything)
+		})
+
+		It("refills the cache from the store", func() {
+			host.CacheMock.On("GetString", "key").Return("", false, nil)
+			host.KVStoreMock.On("Get", "store.key").Return([]byte(`{"v":"stored","s":1}`), true, nil)
+			host.CacheMock.On("SetString", "key", "stored", int64(60)).Return(nil)
+			value, ok := tieredGet("key", 60)
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal("stored"))
+			host.CacheMock.AssertExpectations(GinkgoT())
+		})
+
+		It("doesn't cache restored entries past their expiry", func() {
+			expiresAt := time.Now().Unix() + 3600
+			host.CacheMock.On("GetString", "key").Return("", false, nil)
+			host.KVStoreMock.On("Get", "store.key").Return([]byte(`{"v":"stored","s":1,"e":`+strconv.FormatInt(expiresAt, 10)+`}`), true, nil)
+			host.CacheMock.On("SetString", "key", "stored", mock.MatchedBy(func(ttl int64) bool { return ttl <= 3600 && ttl > 3500 })).Return(nil)
+			_, ok := tieredGet("key", 48*60*60)
+			Expect(ok).To(BeTrue())
+			host.CacheMock.AssertExpectations(GinkgoT())
+		})
+
+		It("misses when neither tier has the key", func() {
+			host.CacheMock.On("GetString", "key").Return("", false, nil)
+			host.KVStoreMock.On("Get", "store.key").Return([]byte(nil), false, nil)
+			_, ok := tieredGet("key", 60)
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// songCacheTTL keeps per-user song details briefly, so repeated NowPlaying calls for the
+// same track don't hit the Subsonic API while still picking up rating changes quickly.
+const songCacheTTL int64 = 60
+
+// subsonicSong captures the per-user fields of a Subsonic song (child) object.
+type subsonicSong struct {
+	ID         string `json:"id"`
+	Title      string `json:"title,omitempty"`
+	Artist     string `json:"artist,omitempty"`
+	AlbumID    string `json:"albumId,omitempty"`
+	Starred    string `json:"starred,omitempty"`
+	UserRating int    `json:"userRating,omitempty"`
+	PlayCount  int64  `json:"playCount,omitempty"`
+
+	// Audio properties, shown by the format badge. BitRate is in kbps, SamplingRate in Hz.
+	Suffix       string `json:"suffix,omitempty"`
+	BitRate      int    `json:"bitRate,omitempty"`
+	BitDepth     int    `json:"bitDepth,omitempty"`
+	SamplingRate int    `json:"samplingRate,omitempty"`
+}
+
+// subsonicStructuredLyrics is an OpenSubsonic structuredLyrics entry.
+// Line start times and the offset are in milliseconds.
+type subsonicStructuredLyrics struct {
+	Synced bool  `json:"synced"`
+	Offset int64 `json:"offset,omitempty"`
+	Line   []struct {
+		Start int64  `json:"start"`
+		Value string `json:"value"`
+	} `json:"line"`
+}
+
+// subsonicArtistInfo is the image part of a getArtistInfo2 response.
+type subsonicArtistInfo struct {
+	SmallImageURL  string `json:"smallImageUrl,omitempty"`
+	MediumImageURL string `json:"mediumImageUrl,omitempty"`
+	LargeImageURL  string `json:"largeImageUrl,omitempty"`
+}
+
+// subsonicShare is a Navidrome share, as returned by createShare.
+type subsonicShare struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// subsonicResponse is the envelope of a Subsonic API JSON response.
+type subsonicResponse struct {
+	Response struct {
+		Status    string        `json:"status"`
+		Song      *subsonicSong `json:"song,omitempty"`
+		PlayQueue *struct {
+			Current string         `json:"current,omitempty"`
+			Entry   []subsonicSong `json:"entry,omitempty"`
+		} `json:"playQueue,omitempty"`
+		ArtistInfo2 *subsonicArtistInfo `json:"artistInfo2,omitempty"`
+		Shares      *struct {
+			Share []subsonicShare `json:"share,omitempty"`
+		} `json:"shares,omitempty"`
+		LyricsList *struct {
+			StructuredLyrics []subsonicStructuredLyrics `json:"structuredLyrics"`
+		} `json:"lyricsList,omitempty"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error,omitempty"`
+	} `json:"subsonic-response"`
+}
+
+// parseSubsonicResponse decodes a Subsonic JSON response, turning failed responses into errors.
+func parseSubsonicResponse(body string) (*subsonicResponse, error) {
+	var resp subsonicResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse Subsonic response: %w", err)
+	}
+	if resp.Response.Status != "ok" {
+		if resp.Response.Error != nil {
+			return nil, fmt.Errorf("subsonic error %d: %s", resp.Response.Error.Code, resp.Response.Error.Message)
+		}
+		return nil, fmt.Errorf("subsonic request failed with status %q", resp.Response.Status)
+	}
+	return &resp, nil
+}
+
+// getSong fetches the song details for trackID as seen by username. Results are cached briefly.
+func getSong(username, trackID string) (*subsonicSong, error) {
+	cacheKey := fmt.Sprintf("subsonic.song.%s.%s", username, trackID)
+	if cached, exists, err := host.CacheGetString(cacheKey); err == nil && exists {
+		var song subsonicSong
+		if err := json.Unmarshal([]byte(cached), &song); err == nil {
+			pdk.Log(pdk.LogDebug, fmt.Sprintf("Cache hit for song details: %s", trackID))
+			return &song, nil
+		}
+	}
+
+	body, err := host.SubsonicAPICall(fmt.Sprintf("/getSong?u=%s&id=%s", url.QueryEscape(username), url.QueryEscape(trackID)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch song details: %w", err)
+	}
+	resp, err := parseSubsonicResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Response.Song == nil {
+		return nil, fmt.Errorf("no song returned for id %s", trackID)
+	}
+
+	if data, err := json.Marshal(resp.Response.Song); err == nil {
+		_ = host.CacheSetString(cacheKey, string(data), songCacheTTL)
+	}
+	return resp.Response.Song, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Subsonic API", func() {
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.SubsonicAPIMock.ExpectedCalls = nil
+		host.SubsonicAPIMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	Describe("parseSubsonicResponse", func() {
+		It("parses a successful response", func() {
+			resp, err := parseSubsonicResponse(`{"subsonic-response":{"status":"ok","song":{"id":"1","userRating":3}}}`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Response.Song.UserRating).To(Equal(3))
+		})
+
+		It("returns the Subsonic error message", func() {
+			_, err := parseSubsonicResponse(`{"subsonic-response":{"status":"failed","error":{"code":70,"message":"Song not found"}}}`)
+			Expect(err).To(MatchError(ContainSubstring("Song not found")))
+		})
+
+		It("returns an error for invalid JSON", func() {
+			_, err := parseSubsonicResponse(`not json`)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("getSong", func() {
+		It("returns cached song details", func() {
+			host.CacheMock.On("GetString", "subsonic.song.testuser.track1").Return(`{"id":"track1","userRating":5}`, true, nil)
+
+			song, err := getSong("testuser", "track1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(song.UserRating).To(Equal(5))
+			host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "Call", mock.Anything)
+		})
+
+		It("fetches and caches song details", func() {
+			host.CacheMock.On("GetString", "subsonic.song.testuser.track1").Return("", false, nil)
+			host.CacheMock.On("SetString", "subsonic.song.testuser.track1", mock.Anything, songCacheTTL).Return(nil)
+			host.SubsonicAPIMock.On("Call", "/getSong?u=testuser&id=track1").
+				Return(`{"subsonic-response":{"status":"ok","song":{"id":"track1","starred":"2024-01-01T00:00:00Z"}}}`, nil)
+
+			song, err := getSong("testuser", "track1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(song.Starred).ToNot(BeEmpty())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "subsonic.song.testuser.track1", mock.Anything, songCacheTTL)
+		})
+
+		It("escapes the username in the request", func() {
+			host.CacheMock.On("GetString", mock.Anything).Return("", false, nil)
+			host.CacheMock.On("SetString", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			host.SubsonicAPIMock.On("Call", "/getSong?u=jane+doe&id=track1").
+				Return(`{"subsonic-response":{"status":"ok","song":{"id":"track1"}}}`, nil)
+
+			_, err := getSong("jane doe", "track1")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns an error when the API call fails", func() {
+			host.CacheMock.On("GetString", "subsonic.song.testuser.track1").Return("", false, nil)
+			host.SubsonicAPIMock.On("Call", "/getSong?u=testuser&id=track1").Return("", errors.New("boom"))
+
+			_, err := getSong("testuser", "track1")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// Configuration keys for text templates. An empty template keeps the default text.
+const (
+	detailsTemplateKey   = "detailstemplate"
+	stateTemplateKey     = "statetemplate"
+	largeTextTemplateKey = "largetexttemplate"
+	smallTextTemplateKey = "smalltexttemplate"
+)
+
+// templateKeys lists all configurable text templates.
+var templateKeys = []string{detailsTemplateKey, stateTemplateKey, largeTextTemplateKey, smallTextTemplateKey}
+
+// Template placeholders, written as {name} in a template.
+const (
+	placeholderTitle      = "title"
+	placeholderArtist     = "artist"
+	placeholderAlbum      = "album"
+	placeholderRating     = "rating"
+	placeholderPlayCount  = "playcount"
+	placeholderPlayBadge  = "playbadge"
+	placeholderNextTitle  = "next_title"
+	placeholderNextArtist = "next_artist"
+	placeholderFormat     = "format"
+)
+
+// renderTemplate replaces {name} placeholders in tmpl with their values.
+// Unknown placeholders are left untouched.
+func renderTemplate(tmpl string, values map[string]string) string {
+	pairs := make([]string, 0, len(values)*2)
+	for name, value := range values {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(tmpl)
+}
+
+// applyTemplate renders the template configured under key, or returns fallback when none is set.
+func applyTemplate(key, fallback string, values map[string]string) string {
+	tmpl, ok := pdk.GetConfig(key)
+	if !ok || strings.TrimSpace(tmpl) == "" {
+		return fallback
+	}
+	return strings.TrimSpace(renderTemplate(tmpl, values))
+}
+
+// templatesUse reports whether any configured template references the placeholder,
+// so optional lookups are only made when their result is shown.
+func templatesUse(placeholder string) bool {
+	for _, key := range templateKeys {
+		if tmpl, ok := pdk.GetConfig(key); ok && strings.Contains(tmpl, "{"+placeholder+"}") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Templates", func() {
+	values := map[string]string{
+		placeholderTitle:  "Karma Police",
+		placeholderArtist: "Radiohead",
+		placeholderAlbum:  "OK Computer",
+		placeholderRating: "",
+	}
+
+	BeforeEach(func() {
+		pdk.ResetMock()
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	Describe("renderTemplate", func() {
+		DescribeTable("replaces placeholders",
+			func(tmpl, expected string) {
+				Expect(renderTemplate(tmpl, values)).To(Equal(expected))
+			},
+			Entry("single placeholder", "{title}", "Karma Police"),
+			Entry("several placeholders", "{artist} — {album}", "Radiohead — OK Computer"),
+			Entry("repeated placeholder", "{title} {title}", "Karma Police Karma Police"),
+			Entry("empty value", "{title} {rating}", "Karma Police "),
+			Entry("unknown placeholder", "{title} {mood}", "Karma Police {mood}"),
+			Entry("no placeholders", "Listening", "Listening"),
+		)
+	})
+
+	Describe("applyTemplate", func() {
+		It("returns the fallback when no template is configured", func() {
+			pdk.PDKMock.On("GetConfig", stateTemplateKey).Return("", false)
+			Expect(applyTemplate(stateTemplateKey, "Radiohead", values)).To(Equal("Radiohead"))
+		})
+
+		It("returns the fallback for a blank template", func() {
+			pdk.PDKMock.On("GetConfig", stateTemplateKey).Return("   ", true)
+			Expect(applyTemplate(stateTemplateKey, "Radiohead", values)).To(Equal("Radiohead"))
+		})
+
+		It("renders and trims the configured template", func() {
+			pdk.PDKMock.On("GetConfig", stateTemplateKey).Return("by {artist} {rating}", true)
+			Expect(applyTemplate(stateTemplateKey, "Radiohead", values)).To(Equal("by Radiohead"))
+		})
+	})
+
+	Describe("templatesUse", func() {
+		It("detects a placeholder in any template", func() {
+			pdk.PDKMock.On("GetConfig", detailsTemplateKey).Return("{title}", true)
+			pdk.PDKMock.On("GetConfig", stateTemplateKey).Return("", false)
+			pdk.PDKMock.On("GetConfig", largeTextTemplateKey).Return("{album} {rating}", true)
+			pdk.PDKMock.On("GetConfig", smallTextTemplateKey).Return("", false)
+			Expect(templatesUse(placeholderRating)).To(BeTrue())
+			Expect(templatesUse(placeholderArtist)).To(BeFalse())
+		})
+	})
+})
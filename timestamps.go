@@ -0,0 +1,70 @@
+package main
+
+import (
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// Configuration keys for the timestamp display mode and clearing tracks without a duration
+const (
+	timestampModeKey = "timestampmode"
+	idleTimeoutKey   = "idletimeout"
+)
+
+// Timestamp display options
+const (
+	timestampModeFull      = "Full"      // Progress bar with elapsed and total time
+	timestampModeElapsed   = "Elapsed"   // Elapsed time only
+	timestampModeRemaining = "Remaining" // Remaining time only
+	timestampModeNone      = "None"      // No timestamps
+)
+
+// clearDelayPadding is added to the remaining track time before the presence is cleared.
+const clearDelayPadding int32 = 5
+
+// Idle timeout for tracks without a duration, in minutes
+const (
+	defaultIdleTimeout = 30
+	maxIdleTimeout     = 24 * 60
+)
+
+// getTimestampMode returns the configured timestamp mode, defaulting to Full.
+func getTimestampMode() string {
+	mode, _ := pdk.GetConfig(timestampModeKey)
+	switch mode {
+	case timestampModeElapsed, timestampModeRemaining, timestampModeNone:
+		return mode
+	}
+	return timestampModeFull
+}
+
+// buildTimestamps returns the activity timestamps for the mode, or nil when none are shown.
+// startTime and endTime are Unix milliseconds; endTime is 0 when the duration is unknown,
+// in which case Full and Remaining fall back to showing the elapsed time.
+func buildTimestamps(mode string, startTime, endTime int64) *activityTimestamps {
+	switch {
+	case mode == timestampModeNone:
+		return nil
+	case mode == timestampModeElapsed || endTime <= 0:
+		return &activityTimestamps{Start: startTime}
+	case mode == timestampModeRemaining:
+		return &activityTimestamps{End: endTime}
+	}
+	return &activityTimestamps{Start: startTime, End: endTime}
+}
+
+// clearDelay returns the number of seconds after which the presence should be cleared.
+// Tracks with a known duration are cleared shortly after they end, in every mode. Tracks
+// with an unknown duration, such as radio streams, have no end to clear at and always show
+// the elapsed time, so they are cleared after the idle timeout instead, unless another
+// NowPlaying replaces the schedule first.
+func clearDelay(duration float32, position int32) int32 {
+	if duration <= 0 {
+		return int32(getIdleTimeout()) * 60
+	}
+	return max(int32(duration)-position, 0) + clearDelayPadding
+}
+
+// getIdleTimeout returns the configured idle timeout in minutes.
+func getIdleTimeout() int {
+	return configInt(idleTimeoutKey, defaultIdleTimeout, 1, maxIdleTimeout)
+}
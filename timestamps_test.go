@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Timestamps", func() {
+	BeforeEach(func() {
+		pdk.ResetMock()
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	Describe("getTimestampMode", func() {
+		DescribeTable("reads the configured mode",
+			func(value string, ok bool, expected string) {
+				pdk.PDKMock.On("GetConfig", timestampModeKey).Return(value, ok)
+				Expect(getTimestampMode()).To(Equal(expected))
+			},
+			Entry("defaults to Full", "", false, timestampModeFull),
+			Entry("Elapsed", "Elapsed", true, timestampModeElapsed),
+			Entry("Remaining", "Remaining", true, timestampModeRemaining),
+			Entry("None", "None", true, timestampModeNone),
+			Entry("unknown values fall back to Full", "Sometimes", true, timestampModeFull),
+		)
+	})
+
+	Describe("buildTimestamps", func() {
+		marshal := func(ts *activityTimestamps) string {
+			data, _ := json.Marshal(activity{Timestamps: ts})
+			return string(data)
+		}
+
+		It("sends start and end in Full mode", func() {
+			Expect(marshal(buildTimestamps(timestampModeFull, 1000, 5000))).To(ContainSubstring(`"timestamps":{"start":1000,"end":5000}`))
+		})
+
+		It("sends only the start in Elapsed mode", func() {
+			Expect(marshal(buildTimestamps(timestampModeElapsed, 1000, 5000))).To(ContainSubstring(`"timestamps":{"start":1000}`))
+		})
+
+		It("sends only the end in Remaining mode", func() {
+			Expect(marshal(buildTimestamps(timestampModeRemaining, 1000, 5000))).To(ContainSubstring(`"timestamps":{"end":5000}`))
+		})
+
+		It("omits timestamps in None mode", func() {
+			Expect(marshal(buildTimestamps(timestampModeNone, 1000, 5000))).ToNot(ContainSubstring(`"timestamps"`))
+		})
+
+		DescribeTable("falls back to elapsed time when the end is unknown",
+			func(mode string) {
+				Expect(buildTimestamps(mode, 1000, 0)).To(Equal(&activityTimestamps{Start: 1000}))
+			},
+			Entry("Full", timestampModeFull),
+			Entry("Remaining", timestampModeRemaining),
+		)
+	})
+
+	Describe("clearDelay", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("GetConfig", idleTimeoutKey).Return("", false).Maybe()
+		})
+
+		It("clears shortly after the track ends", func() {
+			Expect(clearDelay(180, 10)).To(Equal(int32(175)))
+		})
+
+		It("never schedules in the past", func() {
+			Expect(clearDelay(180, 200)).To(Equal(clearDelayPadding))
+		})
+
+		It("clears tracks with an unknown duration after the idle timeout", func() {
+			Expect(clearDelay(0, 30)).To(Equal(int32(defaultIdleTimeout * 60)))
+		})
+
+		It("uses the configured idle timeout", func() {
+			pdk.PDKMock.ExpectedCalls = nil
+			pdk.PDKMock.On("GetConfig", idleTimeoutKey).Return("5", true)
+			Expect(clearDelay(0, 30)).To(Equal(int32(300)))
+		})
+	})
+})
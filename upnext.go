@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// Configuration key for the "up next" hover text
+const upNextKey = "upnext"
+
+// minUpNextCacheTTL is the shortest time the next track is cached for, even for very short tracks.
+const minUpNextCacheTTL int64 = 60
+
+// nextInQueue returns the entry following trackID in the play queue. When the track is not in
+// the queue, the entry following the queue's current track is used. Returns nil at the end of the queue.
+func nextInQueue(current string, entries []subsonicSong, trackID string) *subsonicSong {
+	for _, id := range []string{trackID, current} {
+		if id == "" {
+			continue
+		}
+		for i, e := range entries {
+			if e.ID == id {
+				if i+1 < len(entries) {
+					return &entries[i+1]
+				}
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// fetchUpNext returns the track after trackID in the user's saved play queue. The result is
+// cached per track, so the queue is fetched at most once per track change.
+func fetchUpNext(username, trackID string, ttl int64) (*subsonicSong, error) {
+	cacheKey := fmt.Sprintf("subsonic.next.%s.%s", username, trackID)
+	if cached, exists, err := host.CacheGetString(cacheKey); err == nil && exists {
+		var next subsonicSong
+		if err := json.Unmarshal([]byte(cached), &next); err == nil {
+			pdk.Log(pdk.LogDebug, fmt.Sprintf("Cache hit for next track after %s", trackID))
+			return &next, nil
+		}
+	}
+
+	body, err := host.SubsonicAPICall(fmt.Sprintf("/getPlayQueue?u=%s", url.QueryEscape(username)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch play queue: %w", err)
+	}
+	resp, err := parseSubsonicResponse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	next := &subsonicSong{}
+	if queue := resp.Response.PlayQueue; queue != nil {
+		if n := nextInQueue(queue.Current, queue.Entry, trackID); n != nil {
+			next = n
+		}
+	}
+	if data, err := json.Marshal(next); err == nil {
+		_ = host.CacheSetString(cacheKey, string(data), max(ttl, minUpNextCacheTTL))
+	}
+	return next, nil
+}
+
+// upNextText renders the default "up next" hover text.
+func upNextText(title, artist string) string {
+	if artist == "" {
+		return "Next: " + title
+	}
+	return fmt.Sprintf("Next: %s — %s", title, artist)
+}
+
+// getUpNext looks up the next track in the user's play queue, if it is shown anywhere.
+// The third return value reports whether it should replace the album art hover text.
+func getUpNext(username, trackID string, ttl int64) (title, artist string, largeText bool) {
+	enabled, _ := pdk.GetConfig(upNextKey)
+	largeText = enabled == "true"
+	if !largeText && !templatesUse(placeholderNextTitle) && !templatesUse(placeholderNextArtist) {
+		return "", "", false
+	}
+
+	next, err := fetchUpNext(username, trackID, ttl)
+	if err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to get next track for user %s: %v", username, err))
+		return "", "", largeText
+	}
+	return next.Title, next.Artist, largeText
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Up next", func() {
+	queue := []subsonicSong{
+		{ID: "a", Title: "First", Artist: "Artist A"},
+		{ID: "b", Title: "Second", Artist: "Artist B"},
+		{ID: "c", Title: "Third", Artist: "Artist C"},
+	}
+
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.SubsonicAPIMock.ExpectedCalls = nil
+		host.SubsonicAPIMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	Describe("nextInQueue", func() {
+		It("returns the entry after the playing track", func() {
+			Expect(nextInQueue("a", queue, "b").Title).To(Equal("Third"))
+		})
+
+		It("falls back to the queue's current track", func() {
+			Expect(nextInQueue("a", queue, "unknown").Title).To(Equal("Second"))
+		})
+
+		It("returns nil at the end of the queue", func() {
+			Expect(nextInQueue("", queue, "c")).To(BeNil())
+		})
+
+		It("returns nil for an empty queue", func() {
+			Expect(nextInQueue("", nil, "a")).To(BeNil())
+		})
+	})
+
+	Describe("upNextText", func() {
+		It("includes the artist when known", func() {
+			Expect(upNextText("Third", "Artist C")).To(Equal("Next: Third — Artist C"))
+		})
+
+		It("omits a missing artist", func() {
+			Expect(upNextText("Third", "")).To(Equal("Next: Third"))
+		})
+	})
+
+	Describe("fetchUpNext", func() {
+		It("fetches the play queue and caches the next track", func() {
+			host.CacheMock.On("GetString", "subsonic.next.testuser.b").Return("", false, nil)
+			host.CacheMock.On("SetString", "subsonic.next.testuser.b", mock.Anything, int64(180)).Return(nil)
+			host.SubsonicAPIMock.On("Call", "/getPlayQueue?u=testuser").
+				Return(`{"subsonic-response":{"status":"ok","playQueue":{"current":"b","entry":[{"id":"a","title":"First"},{"id":"b","title":"Second"},{"id":"c","title":"Third","artist":"Artist C"}]}}}`, nil)
+
+			next, err := fetchUpNext("testuser", "b", 180)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(next.Title).To(Equal("Third"))
+			Expect(next.Artist).To(Equal("Artist C"))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "subsonic.next.testuser.b", mock.Anything, int64(180))
+		})
+
+		It("caches an empty result when there is no queue", func() {
+			host.CacheMock.On("GetString", "subsonic.next.testuser.b").Return("", false, nil)
+			host.CacheMock.On("SetString", "subsonic.next.testuser.b", mock.Anything, minUpNextCacheTTL).Return(nil)
+			host.SubsonicAPIMock.On("Call", "/getPlayQueue?u=testuser").
+				Return(`{"subsonic-response":{"status":"ok"}}`, nil)
+
+			next, err := fetchUpNext("testuser", "b", 10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(next.Title).To(BeEmpty())
+		})
+
+		It("uses the cached next track", func() {
+			host.CacheMock.On("GetString", "subsonic.next.testuser.b").Return(`{"id":"c","title":"Third"}`, true, nil)
+
+			next, err := fetchUpNext("testuser", "b", 180)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(next.Title).To(Equal("Third"))
+			host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "Call", mock.Anything)
+		})
+	})
+
+	Describe("getUpNext", func() {
+		It("does not fetch the queue when nothing shows it", func() {
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+
+			title, artist, largeText := getUpNext("testuser", "b", 180)
+			Expect(title).To(BeEmpty())
+			Expect(artist).To(BeEmpty())
+			Expect(largeText).To(BeFalse())
+			host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "Call", mock.Anything)
+		})
+
+		It("returns nothing when the queue cannot be fetched", func() {
+			pdk.PDKMock.On("GetConfig", upNextKey).Return("true", true)
+			host.CacheMock.On("GetString", "subsonic.next.testuser.b").Return("", false, nil)
+			host.SubsonicAPIMock.On("Call", mock.Anything).Return("", errors.New("boom"))
+
+			title, _, largeText := getUpNext("testuser", "b", 180)
+			Expect(title).To(BeEmpty())
+			Expect(largeText).To(BeTrue())
+		})
+	})
+})